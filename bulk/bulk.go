@@ -0,0 +1,288 @@
+// Package bulk exports stored history as CSV, JSON Lines or Parquet files, and
+// merges such files, or another instance's database, back in without
+// duplicating rows.
+package bulk
+
+import (
+	"context"
+	"flipAssistant/database"
+	"flipAssistant/scripts"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Datasets lists what can be exported and imported: item_prices,
+// item_analytics and the two candle tables
+var Datasets = []string{"prices", "analytics", "candles_1h", "candles_1d"}
+
+// importBatch is how many rows are merged per transaction, so a large import
+// doesn't hold the writer for its whole duration
+const importBatch = 1000
+
+// Result counts the rows an import read and how many of them were new
+type Result struct {
+	Read     int `json:"read"`
+	Imported int `json:"imported"`
+}
+
+// CheckDataset returns an error unless dataset is one of Datasets
+func CheckDataset(dataset string) error {
+	for _, d := range Datasets {
+		if d == dataset {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown dataset %q (expected %s)", dataset, strings.Join(Datasets, ", "))
+}
+
+// CheckFormat returns an error unless format is one of Formats
+func CheckFormat(format string) error { return checkFormat(format) }
+
+// Export writes the rows of a dataset selected by filter to w in the given
+// format and returns how many were written
+func Export(ctx context.Context, store database.Store, w io.Writer, dataset, format string, filter database.ExportFilter) (int, error) {
+	if err := CheckFormat(format); err != nil {
+		return 0, err
+	}
+	switch dataset {
+	case "prices":
+		return writeRows(w, format, func(fn func(database.PriceRow) error) error {
+			return store.ExportPrices(ctx, filter, fn)
+		})
+	case "analytics":
+		return writeRows(w, format, func(fn func(database.AnalyticsRow) error) error {
+			return store.ExportAnalytics(ctx, filter, fn)
+		})
+	case "candles_1h", "candles_1d":
+		return writeRows(w, format, func(fn func(database.CandleRow) error) error {
+			return store.ExportCandles(ctx, candleResolution(dataset), filter, fn)
+		})
+	}
+	return 0, CheckDataset(dataset)
+}
+
+func writeRows[T any](w io.Writer, format string, each func(func(T) error) error) (int, error) {
+	out, err := newRowWriter[T](w, format)
+	if err != nil {
+		return 0, err
+	}
+	written := 0
+	err = each(func(row T) error {
+		written++
+		return out.Write(row)
+	})
+	if err != nil {
+		return written, err
+	}
+	return written, out.Close()
+}
+
+// Import merges a file of one dataset in the given format into the database.
+// Rows that are already stored are skipped; imported prices have their items'
+// analytics rebuilt.
+func Import(ctx context.Context, r io.Reader, dataset, format string) (Result, error) {
+	if err := CheckFormat(format); err != nil {
+		return Result{}, err
+	}
+	return load(ctx, dataset, rowSource{
+		prices:    func(fn func(database.PriceRow) error) error { return readRows(r, format, fn) },
+		analytics: func(fn func(database.AnalyticsRow) error) error { return readRows(r, format, fn) },
+		candles:   func(fn func(database.CandleRow) error) error { return readRows(r, format, fn) },
+	})
+}
+
+// Merge copies the rows of one dataset selected by filter from another store,
+// such as a second instance's database, the same way Import does
+func Merge(ctx context.Context, source database.Store, dataset string, filter database.ExportFilter) (Result, error) {
+	return load(ctx, dataset, rowSource{
+		prices: func(fn func(database.PriceRow) error) error {
+			return source.ExportPrices(ctx, filter, fn)
+		},
+		analytics: func(fn func(database.AnalyticsRow) error) error {
+			return source.ExportAnalytics(ctx, filter, fn)
+		},
+		candles: func(fn func(database.CandleRow) error) error {
+			return source.ExportCandles(ctx, candleResolution(dataset), filter, fn)
+		},
+	})
+}
+
+// rowSource feeds every row to import to a callback; only the function of
+// the dataset being imported is called
+type rowSource struct {
+	prices    func(func(database.PriceRow) error) error
+	analytics func(func(database.AnalyticsRow) error) error
+	candles   func(func(database.CandleRow) error) error
+}
+
+func load(ctx context.Context, dataset string, source rowSource) (Result, error) {
+	var result Result
+	switch dataset {
+	case "prices":
+		// Imported rows can be older than stored ones, so the indicators are
+		// rebuilt once for every item that gained rows
+		touched := make(map[string]map[int]bool)
+		b := &batcher[database.PriceRow]{ctx: ctx, result: &result, store: func(ctx context.Context, rows []database.PriceRow) (int, error) {
+			n, err := database.ImportPrices(ctx, rows)
+			if n > 0 {
+				for _, r := range rows {
+					if touched[r.GameMode] == nil {
+						touched[r.GameMode] = make(map[int]bool)
+					}
+					touched[r.GameMode][r.ItemID] = true
+				}
+			}
+			return n, err
+		}}
+		err := source.prices(func(r database.PriceRow) error {
+			if err := normalizeMode(&r.GameMode); err != nil {
+				return err
+			}
+			return b.add(r)
+		})
+		if err := b.finish(err); err != nil {
+			return result, err
+		}
+		for mode, items := range touched {
+			itemIDs := make([]int, 0, len(items))
+			for id := range items {
+				itemIDs = append(itemIDs, id)
+			}
+			if _, err := scripts.RebuildAnalytics(ctx, mode, itemIDs); err != nil {
+				return result, err
+			}
+		}
+
+	case "analytics":
+		b := &batcher[database.AnalyticsRow]{ctx: ctx, result: &result, store: database.ImportAnalytics}
+		err := source.analytics(func(r database.AnalyticsRow) error {
+			if err := normalizeMode(&r.GameMode); err != nil {
+				return err
+			}
+			return b.add(r)
+		})
+		if err := b.finish(err); err != nil {
+			return result, err
+		}
+
+	case "candles_1h", "candles_1d":
+		resolution := candleResolution(dataset)
+		b := &batcher[database.CandleRow]{ctx: ctx, result: &result, store: func(ctx context.Context, rows []database.CandleRow) (int, error) {
+			return database.ImportCandles(ctx, resolution, rows)
+		}}
+		err := source.candles(func(r database.CandleRow) error {
+			if err := normalizeMode(&r.GameMode); err != nil {
+				return err
+			}
+			return b.add(r)
+		})
+		if err := b.finish(err); err != nil {
+			return result, err
+		}
+
+	default:
+		return result, CheckDataset(dataset)
+	}
+
+	log.Printf("Imported %d of %d %s rows; the rest were already stored", result.Imported, result.Read, dataset)
+	return result, nil
+}
+
+// batcher collects rows and merges them importBatch at a time
+type batcher[T any] struct {
+	ctx    context.Context
+	store  func(context.Context, []T) (int, error)
+	result *Result
+	rows   []T
+}
+
+func (b *batcher[T]) add(row T) error {
+	b.rows = append(b.rows, row)
+	b.result.Read++
+	if len(b.rows) < importBatch {
+		return nil
+	}
+	return b.flush()
+}
+
+func (b *batcher[T]) flush() error {
+	if len(b.rows) == 0 {
+		return nil
+	}
+	n, err := b.store(b.ctx, b.rows)
+	b.result.Imported += n
+	b.rows = b.rows[:0]
+	return err
+}
+
+// finish merges the last partial batch unless reading the rows failed
+func (b *batcher[T]) finish(err error) error {
+	if err != nil {
+		return err
+	}
+	return b.flush()
+}
+
+// normalizeMode fills in the main game for rows without a game mode and
+// rejects unknown ones
+func normalizeMode(mode *string) error {
+	if *mode == "" {
+		*mode = database.DefaultGameMode
+	}
+	if !database.GameModes[*mode] {
+		return fmt.Errorf("unknown game mode %q", *mode)
+	}
+	return nil
+}
+
+func candleResolution(dataset string) string {
+	return strings.TrimPrefix(dataset, "candles_")
+}
+
+// ParseFilter builds an export filter from its text form: a game mode, a
+// comma-separated item list (empty for every item) and dates as YYYY-MM-DD or
+// RFC 3339 (empty for an open end)
+func ParseFilter(mode, items, since, until string) (database.ExportFilter, error) {
+	filter := database.ExportFilter{Mode: mode}
+	if !database.GameModes[mode] {
+		return filter, fmt.Errorf("unknown game mode %q (expected osrs, dmm or fsw)", mode)
+	}
+	for _, part := range strings.Split(items, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return filter, fmt.Errorf("invalid item ID %q", part)
+		}
+		filter.ItemIDs = append(filter.ItemIDs, id)
+	}
+
+	var err error
+	if filter.Since, err = parseDate(since); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseDate(until); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,280 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"flipAssistant/database"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openDB opens and migrates a fresh SQLite database, closing it when the test
+// ends or the next one is opened
+func openDB(t *testing.T) {
+	t.Helper()
+	database.InitDB(database.Options{DSN: filepath.Join(t.TempDir(), "test.db"), BusyTimeout: 5 * time.Second})
+	t.Cleanup(database.Close)
+}
+
+var start = time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+
+// priceRows returns n quotes of an item ten minutes apart. Every third quote
+// has a side that never traded.
+func priceRows(mode string, itemID, n int) []database.PriceRow {
+	rows := make([]database.PriceRow, n)
+	for i := range rows {
+		at := start.Add(time.Duration(i) * 10 * time.Minute)
+		buyTime, sellTime := at.Add(-time.Minute), at
+		rows[i] = database.PriceRow{ItemID: itemID, GameMode: mode, Timestamp: at,
+			BuyPrice: 100 + i%7, SellPrice: 110 + i%5, BuyTime: &buyTime, SellTime: &sellTime, Quality: "ok"}
+		if i%3 == 2 {
+			rows[i].BuyTime = nil
+		}
+	}
+	return rows
+}
+
+// priceFile writes rows as a file in the given format
+func priceFile(t *testing.T, format string, rows []database.PriceRow) []byte {
+	t.Helper()
+	var file bytes.Buffer
+	_, err := writeRows(&file, format, func(fn func(database.PriceRow) error) error {
+		for _, r := range rows {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Bytes()
+}
+
+// seed stores a few rows of every dataset, and some prices of a second game
+// mode that an export of the main game must leave out
+func seed(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	prices := append(priceRows("osrs", 2, 30), priceRows("dmm", 560, 10)...)
+	if _, err := database.ImportPrices(ctx, prices); err != nil {
+		t.Fatal(err)
+	}
+
+	var analytics []database.AnalyticsRow
+	for i, item := range []int{2, 560} {
+		r := database.AnalyticsRow{ItemID: item, GameMode: "osrs", SMA5Buy: 101.2, SMA5Sell: 111.8, LastUpdated: start.Add(time.Duration(i) * time.Hour)}
+		for j, field := range []*float64{
+			&r.RSI, &r.MACDLine, &r.MACDSignal, &r.MACDHist,
+			&r.RSISell, &r.MACDLineSell, &r.MACDSignalSell, &r.MACDHistSell,
+			&r.RSIMid, &r.MACDLineMid, &r.MACDSignalMid, &r.MACDHistMid,
+			&r.RSISpread, &r.MACDLineSpread, &r.MACDSignalSpread, &r.MACDHistSpread,
+		} {
+			*field = float64(i*100+j) + 0.25
+		}
+		analytics = append(analytics, r)
+	}
+	if _, err := database.ImportAnalytics(ctx, analytics); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, resolution := range []string{"1h", "1d"} {
+		var candles []database.CandleRow
+		for i := 0; i < 3; i++ {
+			candles = append(candles, database.CandleRow{ItemID: 2, GameMode: "osrs", Timestamp: start.Add(time.Duration(i) * 24 * time.Hour),
+				BuyOpen: 100, BuyHigh: 105 + i, BuyLow: 95, BuyClose: 101, SellOpen: 110, SellHigh: 115, SellLow: 105 - i, SellClose: 111, Ticks: 6 + i})
+		}
+		if _, err := database.ImportCandles(ctx, resolution, candles); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// stored reads every row of a dataset in a game mode back out of the database
+func stored(t *testing.T, dataset, mode string) []interface{} {
+	t.Helper()
+	ctx := context.Background()
+	store := database.NewSQLStore(database.ReadDB)
+	filter := database.ExportFilter{Mode: mode}
+	var rows []interface{}
+	var err error
+	switch dataset {
+	case "prices":
+		err = store.ExportPrices(ctx, filter, func(r database.PriceRow) error { rows = append(rows, r); return nil })
+	case "analytics":
+		err = store.ExportAnalytics(ctx, filter, func(r database.AnalyticsRow) error { rows = append(rows, r); return nil })
+	default:
+		err = store.ExportCandles(ctx, candleResolution(dataset), filter, func(r database.CandleRow) error { rows = append(rows, r); return nil })
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, dataset := range Datasets {
+		for _, format := range Formats {
+			t.Run(dataset+"/"+format, func(t *testing.T) {
+				openDB(t)
+				seed(t)
+				want := stored(t, dataset, "osrs")
+				if len(want) == 0 {
+					t.Fatal("nothing seeded")
+				}
+
+				var file bytes.Buffer
+				n, err := Export(ctx, database.NewSQLStore(database.ReadDB), &file, dataset, format, database.ExportFilter{Mode: "osrs"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != len(want) {
+					t.Errorf("exported %d rows, want %d", n, len(want))
+				}
+				database.Close()
+
+				openDB(t)
+				result, err := Import(ctx, bytes.NewReader(file.Bytes()), dataset, format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Read != len(want) || result.Imported != len(want) {
+					t.Errorf("import %+v, want %d rows read and imported", result, len(want))
+				}
+				got := stored(t, dataset, "osrs")
+				if dataset == "prices" {
+					// Only the exported mode comes back
+					if other := stored(t, dataset, "dmm"); len(other) != 0 {
+						t.Errorf("%d dmm rows imported from an osrs export", len(other))
+					}
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("rows changed in the round trip:\n got %+v\nwant %+v", got, want)
+				}
+
+				// Importing the same file again adds nothing
+				again, err := Import(ctx, bytes.NewReader(file.Bytes()), dataset, format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if again.Read != len(want) || again.Imported != 0 {
+					t.Errorf("second import %+v, want %d rows read and none imported", again, len(want))
+				}
+				if got := stored(t, dataset, "osrs"); !reflect.DeepEqual(got, want) {
+					t.Errorf("second import changed the rows")
+				}
+			})
+		}
+	}
+}
+
+func TestImportBatches(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	// A bad row after the first full batch stops the import, but the batch
+	// before it has already been merged
+	rows := priceRows("osrs", 4151, importBatch+500)
+	rows[importBatch+100].GameMode = "league"
+	file := priceFile(t, "csv", rows)
+
+	result, err := Import(ctx, bytes.NewReader(file), "prices", "csv")
+	if err == nil || !strings.Contains(err.Error(), `unknown game mode "league"`) {
+		t.Fatalf("got %v, want the bad game mode", err)
+	}
+	if result.Imported != importBatch {
+		t.Errorf("imported %d rows before the bad one, want one batch of %d", result.Imported, importBatch)
+	}
+	if n := len(stored(t, "prices", "osrs")); n != importBatch {
+		t.Errorf("%d rows stored, want %d", n, importBatch)
+	}
+
+	// Fixed, the whole file merges, the first batch being skipped as stored
+	rows[importBatch+100].GameMode = ""
+	file = priceFile(t, "jsonl", rows)
+	result, err = Import(ctx, bytes.NewReader(file), "prices", "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Read != len(rows) || result.Imported != 500 {
+		t.Errorf("import %+v, want %d read and 500 imported", result, len(rows))
+	}
+}
+
+func TestImportRebuildsAnalytics(t *testing.T) {
+	ctx := context.Background()
+	openDB(t)
+
+	// An item not in the file keeps the analytics it has
+	untouched := database.AnalyticsRow{ItemID: 560, GameMode: "osrs", SMA5Buy: 7, SMA5Sell: 8, RSI: 55, LastUpdated: start}
+	if _, err := database.ImportAnalytics(ctx, []database.AnalyticsRow{untouched}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := append(priceRows("osrs", 2, 40), priceRows("dmm", 2, 20)...)
+	file := priceFile(t, "csv", rows)
+	if _, err := Import(ctx, bytes.NewReader(file), "prices", "csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	store := database.NewSQLStore(database.ReadDB)
+	for _, tt := range []struct {
+		mode string
+		n    int
+	}{{"osrs", 40}, {"dmm", 20}} {
+		var buys, sells []float64
+		for _, r := range priceRows(tt.mode, 2, tt.n) {
+			buys = append(buys, float64(r.BuyPrice))
+			sells = append(sells, float64(r.SellPrice))
+		}
+		want := database.AnalyticsFromPrices(tt.mode, 2, buys, sells)
+
+		analytics, err := store.Analytics(ctx, tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got *database.ItemAnalytics
+		for i := range analytics {
+			if analytics[i].ItemID == 2 {
+				got = &analytics[i]
+			}
+		}
+		if got == nil {
+			t.Fatalf("%s: no analytics for the imported item", tt.mode)
+		}
+		if !closeAnalytics(*got, want) {
+			t.Errorf("%s: analytics %+v, want the full recompute %+v", tt.mode, *got, want)
+		}
+	}
+
+	rest := stored(t, "analytics", "osrs")
+	for _, r := range rest {
+		if a := r.(database.AnalyticsRow); a.ItemID == 560 && (a.SMA5Buy != 7 || a.RSI != 55) {
+			t.Errorf("untouched item's analytics changed to %+v", a)
+		}
+	}
+}
+
+// closeAnalytics compares analytics allowing for floating-point rounding
+func closeAnalytics(a, b database.ItemAnalytics) bool {
+	fields := func(x database.ItemAnalytics) []float64 {
+		out := []float64{x.SMA5Buy, x.SMA5Sell}
+		for _, in := range []database.Indicators{x.Buy, x.Sell, x.Mid, x.Spread} {
+			out = append(out, in.RSI, in.MACDLine, in.MACDSignal, in.MACDHist)
+		}
+		return out
+	}
+	fa, fb := fields(a), fields(b)
+	for i := range fa {
+		if math.Abs(fa[i]-fb[i]) > 1e-9 {
+			return false
+		}
+	}
+	return a.ItemID == b.ItemID && a.GameMode == b.GameMode
+}
@@ -0,0 +1,362 @@
+package bulk
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flipAssistant/database"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Formats lists the supported file formats
+var Formats = []string{"csv", "jsonl", "parquet"}
+
+// FormatForPath picks the format matching a file's extension, or "" if none does
+func FormatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	case ".parquet":
+		return "parquet"
+	}
+	return ""
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv"
+	case "jsonl":
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// checkFormat returns an error unless format is one of Formats
+func checkFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format %q (expected csv, jsonl or parquet)", format)
+}
+
+// rowWriter encodes rows of one type to an output; Close flushes what is buffered
+type rowWriter[T any] interface {
+	Write(row T) error
+	Close() error
+}
+
+func newRowWriter[T any](w io.Writer, format string) (rowWriter[T], error) {
+	switch format {
+	case "csv":
+		return newCSVWriter[T](w)
+	case "jsonl":
+		return &jsonlWriter[T]{enc: json.NewEncoder(w)}, nil
+	case "parquet":
+		return &parquetWriter[T]{w: parquet.NewGenericWriter[T](w)}, nil
+	}
+	return nil, checkFormat(format)
+}
+
+// readRows decodes rows of one type from an input and calls fn with each,
+// stopping at the first error
+func readRows[T any](r io.Reader, format string, fn func(T) error) error {
+	switch format {
+	case "csv":
+		return readCSV(r, fn)
+	case "jsonl":
+		return readJSONL(r, fn)
+	case "parquet":
+		return readParquet(r, fn)
+	}
+	return checkFormat(format)
+}
+
+// CSV columns are the rows' JSON field names. Times are written in the
+// database's UTC layout and read in that layout or RFC 3339; an empty cell is
+// a missing time.
+
+type csvWriter[T any] struct {
+	w      *csv.Writer
+	fields []int
+	record []string
+}
+
+func newCSVWriter[T any](w io.Writer) (*csvWriter[T], error) {
+	names, fields := csvColumns(reflect.TypeOf(*new(T)))
+	cw := &csvWriter[T]{w: csv.NewWriter(w), fields: fields, record: make([]string, len(fields))}
+	return cw, cw.w.Write(names)
+}
+
+func (cw *csvWriter[T]) Write(row T) error {
+	v := reflect.ValueOf(row)
+	for i, field := range cw.fields {
+		cw.record[i] = formatCell(v.Field(field))
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter[T]) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func readCSV[T any](r io.Reader, fn func(T) error) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Match the header to the row's fields by name; unknown columns are ignored
+	names, fields := csvColumns(reflect.TypeOf(*new(T)))
+	byName := make(map[string]int, len(names))
+	for i, name := range names {
+		byName[name] = fields[i]
+	}
+	columns := make([]int, len(header))
+	for i, name := range header {
+		field, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			field = -1
+		}
+		columns[i] = field
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var row T
+		v := reflect.ValueOf(&row).Elem()
+		for i, cell := range record {
+			if i >= len(columns) || columns[i] < 0 {
+				continue
+			}
+			if err := parseCell(v.Field(columns[i]), cell); err != nil {
+				return fmt.Errorf("line %d, column %s: %v", line, header[i], err)
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// csvColumns returns the column names of a row type and the index of the field
+// behind each
+func csvColumns(t reflect.Type) ([]string, []int) {
+	var names []string
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		names = append(names, name)
+		fields = append(fields, i)
+	}
+	return names, fields
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func formatCell(v reflect.Value) string {
+	switch {
+	case v.Type() == timeType:
+		return formatTime(v.Interface().(time.Time))
+	case v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			return ""
+		}
+		return formatCell(v.Elem())
+	case v.Kind() == reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case v.Kind() == reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	default:
+		return v.String()
+	}
+}
+
+func parseCell(v reflect.Value, cell string) error {
+	switch {
+	case v.Type() == timeType:
+		t, err := parseTime(cell)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+	case v.Kind() == reflect.Pointer:
+		if cell == "" {
+			v.SetZero()
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := parseCell(elem.Elem(), cell); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.ParseInt(cell, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		v.SetString(cell)
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(database.TimestampLayout)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(database.TimestampLayout, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+type jsonlWriter[T any] struct {
+	enc *json.Encoder
+}
+
+func (jw *jsonlWriter[T]) Write(row T) error { return jw.enc.Encode(row) }
+
+func (jw *jsonlWriter[T]) Close() error { return nil }
+
+func readJSONL[T any](r io.Reader, fn func(T) error) error {
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var row T
+		if err := dec.Decode(&row); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// parquetBatch is how many rows are buffered per write to the parquet encoder
+const parquetBatch = 1024
+
+type parquetWriter[T any] struct {
+	w     *parquet.GenericWriter[T]
+	batch []T
+}
+
+func (pw *parquetWriter[T]) Write(row T) error {
+	pw.batch = append(pw.batch, row)
+	if len(pw.batch) < parquetBatch {
+		return nil
+	}
+	return pw.flush()
+}
+
+func (pw *parquetWriter[T]) flush() error {
+	_, err := pw.w.Write(pw.batch)
+	pw.batch = pw.batch[:0]
+	return err
+}
+
+func (pw *parquetWriter[T]) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+	return pw.w.Close()
+}
+
+// readParquet reads a parquet file, which needs random access: regular files
+// are read in place and other inputs, including a piped stdin, are buffered in
+// memory first
+func readParquet[T any](r io.Reader, fn func(T) error) (err error) {
+	var input io.ReaderAt
+	var size int64
+	if f, ok := r.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			input, size = f, info.Size()
+		}
+	}
+	if input == nil {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		input, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	file, err := parquet.OpenFile(input, size)
+	if err != nil {
+		return err
+	}
+
+	// The reader panics on a file whose schema doesn't fit the rows
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("reading parquet: %v", p)
+		}
+	}()
+	reader := parquet.NewGenericReader[T](file)
+	defer reader.Close()
+
+	batch := make([]T, parquetBatch)
+	for {
+		n, err := reader.Read(batch)
+		for _, row := range batch[:n] {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
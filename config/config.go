@@ -0,0 +1,244 @@
+package config
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds runtime settings, read from FLIP_* environment variables
+type Config struct {
+	// DatabaseDSN is the SQLite database file, as a path or file: URI, or a
+	// postgres:// URL
+	DatabaseDSN string
+	// DatabaseBusyTimeout is how long a SQLite connection waits for a lock
+	DatabaseBusyTimeout time.Duration
+	// DatabaseWAL enables write-ahead logging on SQLite
+	DatabaseWAL bool
+	// DatabaseReaders bounds the read-only connection pool used by the API
+	DatabaseReaders int
+
+	// PriceSource selects where /latest snapshots come from: live, fixture,
+	// replay (files in ReplayDir) or archive (the raw_snapshots table). The
+	// last two stop the latest-prices job after their final snapshot.
+	PriceSource string
+	// WikiBaseURL is the root of the prices API used by the live source, the
+	// averages and mapping jobs and backfill; the game mode is appended, e.g.
+	// <root>/dmm/latest
+	WikiBaseURL string
+	// FixturePath is the /latest JSON file served by the fixture source
+	FixturePath string
+	// ReplayDir holds the archived snapshots stepped through by the replay source
+	ReplayDir string
+	// ReplaySpeed compresses the time between replayed snapshots (0 = no
+	// pacing). One snapshot is read per latest-prices run, so replay never
+	// goes faster than one snapshot per FetchInterval.
+	ReplaySpeed float64
+	// ItemDBBaseURL is the root of the official itemdb API used for guide prices
+	ItemDBBaseURL string
+	// GameModes are the markets fetched by every price job unless JobModes
+	// overrides them
+	GameModes []string
+	// JobModes holds the markets fetched by each price job, keyed by job name
+	JobModes map[string][]string
+	// FetchInterval is the pause between price snapshots
+	FetchInterval time.Duration
+	// AnalyticsWorkers bounds how many items have analytics updated at once
+	AnalyticsWorkers int
+
+	// Averages5mInterval, Averages1hInterval and Averages24hInterval pace the
+	// averaged price jobs
+	Averages5mInterval  time.Duration
+	Averages1hInterval  time.Duration
+	Averages24hInterval time.Duration
+	// MappingInterval paces the item catalog refresh
+	MappingInterval time.Duration
+	// ItemsFile is a local /mapping JSON file imported into the item catalog at
+	// startup and whenever it changes ("" = none)
+	ItemsFile string
+	// ItemsFilePoll is how often ItemsFile is checked for changes
+	ItemsFilePoll time.Duration
+	// GuideInterval paces the guide price job; GuideDelay is the pause between
+	// its per-item itemdb requests
+	GuideInterval time.Duration
+	GuideDelay    time.Duration
+	// RetentionInterval paces the retention job
+	RetentionInterval time.Duration
+	// JobJitter is the maximum random delay added to each job interval
+	JobJitter time.Duration
+
+	// QualityRejectMissingSide quarantines quotes where one side has never traded
+	QualityRejectMissingSide bool
+	// QualityMaxJump quarantines prices more than this many times above or
+	// below the recent median (0 disables the check)
+	QualityMaxJump float64
+	// QualityMedianWindow is how many recent quotes the median is taken over,
+	// counting those quarantined as spikes
+	QualityMedianWindow int
+	// QualityMaxAge quarantines quotes whose older side traded longer ago than
+	// this (0 disables the check)
+	QualityMaxAge time.Duration
+
+	// DivergenceThreshold is the default relative gap between the wiki and
+	// guide prices at which /price-divergence flags an item
+	DivergenceThreshold float64
+
+	// AdminToken enables the routes that change stored data, which then require
+	// it as an "Authorization: Bearer" header ("" = routes not registered)
+	AdminToken string
+	// MaxImportSize bounds the request body of POST /import, in bytes
+	MaxImportSize int64
+
+	// LogRetention is how long job run history and fetch failures are kept (0 = forever)
+	LogRetention time.Duration
+	// SnapshotRetention is how long archived raw snapshots are kept (0 = forever).
+	// With the default intervals each game mode archives about 460 compressed
+	// responses a day, tens of megabytes, so keeping them forever grows the
+	// database without bound.
+	SnapshotRetention time.Duration
+	// PriceRetention is how long raw price ticks are kept before being rolled
+	// into 1h and 1d candles (0 = forever). Every tracked item gains a quote per
+	// fetch, so raw ticks are the fastest-growing table by far.
+	PriceRetention time.Duration
+	// HourlyCandleRetention is how long 1h candles are kept; 1d candles are
+	// kept forever (0 = forever)
+	HourlyCandleRetention time.Duration
+}
+
+// PriceJobs are the jobs that fetch a market, each of which can be pointed at
+// its own game modes with FLIP_<JOB>_MODES, e.g. FLIP_LATEST_PRICES_MODES
+var PriceJobs = []string{"latest-prices", "averages-5m", "averages-1h", "averages-24h"}
+
+// Load reads the configuration from the environment, falling back to defaults
+func Load() Config {
+	gameModes := getList("FLIP_GAME_MODES", []string{"osrs"})
+	jobModes := make(map[string][]string, len(PriceJobs))
+	for _, job := range PriceJobs {
+		key := "FLIP_" + strings.ToUpper(strings.ReplaceAll(job, "-", "_")) + "_MODES"
+		jobModes[job] = getList(key, gameModes)
+	}
+
+	return Config{
+		DatabaseDSN:         getString("FLIP_DB_DSN", "flips.db"),
+		DatabaseBusyTimeout: getDuration("FLIP_DB_BUSY_TIMEOUT", 5*time.Second),
+		DatabaseWAL:         getBool("FLIP_DB_WAL", true),
+		DatabaseReaders:     getInt("FLIP_DB_READERS", runtime.NumCPU()),
+
+		PriceSource:      getString("FLIP_PRICE_SOURCE", "live"),
+		WikiBaseURL:      getString("FLIP_WIKI_BASE_URL", "https://prices.runescape.wiki/api/v1"),
+		FixturePath:      getString("FLIP_FIXTURE_PATH", "fixtures/latest.json"),
+		ReplayDir:        getString("FLIP_REPLAY_DIR", "snapshots"),
+		ReplaySpeed:      getFloat("FLIP_REPLAY_SPEED", 1),
+		ItemDBBaseURL:    getString("FLIP_ITEMDB_BASE_URL", "https://secure.runescape.com/m=itemdb_oldschool"),
+		GameModes:        gameModes,
+		JobModes:         jobModes,
+		FetchInterval:    getDuration("FLIP_FETCH_INTERVAL", 10*time.Minute),
+		AnalyticsWorkers: getInt("FLIP_ANALYTICS_WORKERS", runtime.NumCPU()),
+
+		Averages5mInterval:  getDuration("FLIP_AVERAGES_5M_INTERVAL", 5*time.Minute),
+		Averages1hInterval:  getDuration("FLIP_AVERAGES_1H_INTERVAL", time.Hour),
+		Averages24hInterval: getDuration("FLIP_AVERAGES_24H_INTERVAL", 6*time.Hour),
+		MappingInterval:     getDuration("FLIP_MAPPING_INTERVAL", 24*time.Hour),
+		ItemsFile:           getString("FLIP_ITEMS_FILE", ""),
+		ItemsFilePoll:       getDuration("FLIP_ITEMS_FILE_POLL", 5*time.Second),
+		GuideInterval:       getDuration("FLIP_GUIDE_INTERVAL", 24*time.Hour),
+		GuideDelay:          getDuration("FLIP_GUIDE_DELAY", time.Second),
+		RetentionInterval:   getDuration("FLIP_RETENTION_INTERVAL", 24*time.Hour),
+		JobJitter:           getDuration("FLIP_JOB_JITTER", 30*time.Second),
+
+		QualityRejectMissingSide: getBool("FLIP_QUALITY_REJECT_MISSING_SIDE", true),
+		QualityMaxJump:           getFloat("FLIP_QUALITY_MAX_JUMP", 3),
+		QualityMedianWindow:      getInt("FLIP_QUALITY_MEDIAN_WINDOW", 12),
+		QualityMaxAge:            getDuration("FLIP_QUALITY_MAX_AGE", 24*time.Hour),
+
+		DivergenceThreshold: getFloat("FLIP_DIVERGENCE_THRESHOLD", 0.15),
+
+		AdminToken:    getString("FLIP_ADMIN_TOKEN", ""),
+		MaxImportSize: int64(getInt("FLIP_MAX_IMPORT_SIZE", 256<<20)),
+
+		LogRetention:          getDuration("FLIP_LOG_RETENTION", 30*24*time.Hour),
+		SnapshotRetention:     getDuration("FLIP_SNAPSHOT_RETENTION", 30*24*time.Hour),
+		PriceRetention:        getDuration("FLIP_PRICE_RETENTION", 14*24*time.Hour),
+		HourlyCandleRetention: getDuration("FLIP_HOURLY_CANDLE_RETENTION", 0),
+	}
+}
+
+func getString(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getList reads a comma-separated list, ignoring empty entries
+func getList(key string, fallback []string) []string {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	var list []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+func getInt(key string, fallback int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %v", key, v, fallback)
+		return fallback
+	}
+	return i
+}
+
+func getBool(key string, fallback bool) bool {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %v", key, v, fallback)
+		return fallback
+	}
+	return b
+}
+
+func getFloat(key string, fallback float64) float64 {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %v", key, v, fallback)
+		return fallback
+	}
+	return f
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %v", key, v, fallback)
+		return fallback
+	}
+	return d
+}
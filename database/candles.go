@@ -0,0 +1,247 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CandleTables maps each candle resolution to the table its candles are
+// stored in
+var CandleTables = map[string]string{
+	"1h": "item_candles_1h",
+	"1d": "item_candles_1d",
+}
+
+// CandleResolution is a candle table's resolution name and bucket size
+type CandleResolution struct {
+	Name string
+	Size time.Duration
+}
+
+// CandleResolutions lists the candle resolutions, finest first
+var CandleResolutions = []CandleResolution{
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+// Candle is the open, high, low and close of both sides of an item's price
+// over one bucket, which starts at Timestamp. A price of 0 means that side did
+// not trade in the bucket.
+type Candle struct {
+	Timestamp                              time.Time
+	BuyOpen, BuyHigh, BuyLow, BuyClose     int
+	SellOpen, SellHigh, SellLow, SellClose int
+	Ticks                                  int // Raw quotes rolled into the candle
+}
+
+// add folds the next quote of the bucket into the candle
+func (c *Candle) add(buy, sell int) {
+	foldSide(&c.BuyOpen, &c.BuyHigh, &c.BuyLow, &c.BuyClose, buy)
+	foldSide(&c.SellOpen, &c.SellHigh, &c.SellLow, &c.SellClose, sell)
+	c.Ticks++
+}
+
+// foldSide updates one side's OHLC with a price, ignoring 0 (no trade)
+func foldSide(open, high, low, close *int, price int) {
+	if price <= 0 {
+		return
+	}
+	if *open == 0 {
+		*open = price
+	}
+	if price > *high {
+		*high = price
+	}
+	if *low == 0 || price < *low {
+		*low = price
+	}
+	*close = price
+}
+
+// CompactPrices rolls raw item_prices ticks older than rawAge into 1h and 1d
+// candles and deletes them, then deletes 1h candles older than hourlyAge. Ages
+// are rounded back to UTC midnight so only whole days are rolled up, and a zero
+// age keeps that data forever. Each item is compacted in its own transaction;
+// ticks that arrive late for an existing candle widen its range and add to its
+// tick count. It returns the number of raw ticks rolled up.
+func CompactPrices(ctx context.Context, rawAge, hourlyAge time.Duration) (int64, error) {
+	var compacted int64
+	if rawAge > 0 {
+		cutoff := candleCutoff(rawAge)
+
+		rows, err := ReadDB.QueryContext(ctx, `
+			SELECT DISTINCT item_id, game_mode FROM item_prices WHERE timestamp < ?
+		`, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		type market struct {
+			itemID int
+			mode   string
+		}
+		var markets []market
+		for rows.Next() {
+			var m market
+			if err := rows.Scan(&m.itemID, &m.mode); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			markets = append(markets, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+
+		for _, m := range markets {
+			n, err := compactItemPrices(ctx, m.mode, m.itemID, cutoff)
+			if err != nil {
+				return compacted, fmt.Errorf("item %d (%s): %v", m.itemID, m.mode, err)
+			}
+			compacted += n
+		}
+	}
+
+	if hourlyAge > 0 {
+		if _, err := DB.ExecContext(ctx, `DELETE FROM item_candles_1h WHERE timestamp < ?`, candleCutoff(hourlyAge)); err != nil {
+			return compacted, err
+		}
+	}
+	return compacted, nil
+}
+
+// candleCutoff returns the UTC midnight at or before age ago
+func candleCutoff(age time.Duration) string {
+	return time.Now().Add(-age).UTC().Truncate(24 * time.Hour).Format(TimestampLayout)
+}
+
+// compactItemPrices rolls one item's ticks before cutoff into candles of every
+// resolution and deletes them
+func compactItemPrices(ctx context.Context, mode string, itemID int, cutoff string) (int64, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT timestamp, buy_price, sell_price FROM item_prices
+		WHERE item_id = ? AND game_mode = ? AND timestamp < ?
+		ORDER BY timestamp ASC, id ASC
+	`, itemID, mode, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	candles := make([][]Candle, len(CandleResolutions))
+	for rows.Next() {
+		var timestamp time.Time
+		var buy, sell int
+		if err := rows.Scan(&timestamp, &buy, &sell); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		for i, res := range CandleResolutions {
+			bucket := timestamp.UTC().Truncate(res.Size)
+			if n := len(candles[i]); n == 0 || !candles[i][n-1].Timestamp.Equal(bucket) {
+				candles[i] = append(candles[i], Candle{Timestamp: bucket})
+			}
+			candles[i][len(candles[i])-1].add(buy, sell)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, res := range CandleResolutions {
+		if err := storeCandles(ctx, tx, CandleTables[res.Name], mode, itemID, candles[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM item_prices WHERE item_id = ? AND game_mode = ? AND timestamp < ?
+	`, itemID, mode, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, tx.Commit()
+}
+
+// storeCandles upserts an item's candles into table. An existing candle keeps
+// its open and close and is widened by the new high and low. Columns of the
+// existing row are qualified with the table name, as PostgreSQL requires.
+func storeCandles(ctx context.Context, exec Execer, table, mode string, itemID int, candles []Candle) error {
+	for _, c := range candles {
+		_, err := exec.ExecContext(ctx, fmt.Sprintf(`
+			INSERT INTO %[1]s (item_id, game_mode, timestamp,
+				buy_open, buy_high, buy_low, buy_close,
+				sell_open, sell_high, sell_low, sell_close, ticks)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(item_id, game_mode, timestamp) DO UPDATE SET
+				buy_open = CASE WHEN %[1]s.buy_open = 0 THEN excluded.buy_open ELSE %[1]s.buy_open END,
+				buy_high = CASE WHEN excluded.buy_high > %[1]s.buy_high THEN excluded.buy_high ELSE %[1]s.buy_high END,
+				buy_low = CASE WHEN %[1]s.buy_low = 0 OR (excluded.buy_low > 0 AND excluded.buy_low < %[1]s.buy_low) THEN excluded.buy_low ELSE %[1]s.buy_low END,
+				buy_close = CASE WHEN %[1]s.buy_close = 0 THEN excluded.buy_close ELSE %[1]s.buy_close END,
+				sell_open = CASE WHEN %[1]s.sell_open = 0 THEN excluded.sell_open ELSE %[1]s.sell_open END,
+				sell_high = CASE WHEN excluded.sell_high > %[1]s.sell_high THEN excluded.sell_high ELSE %[1]s.sell_high END,
+				sell_low = CASE WHEN %[1]s.sell_low = 0 OR (excluded.sell_low > 0 AND excluded.sell_low < %[1]s.sell_low) THEN excluded.sell_low ELSE %[1]s.sell_low END,
+				sell_close = CASE WHEN %[1]s.sell_close = 0 THEN excluded.sell_close ELSE %[1]s.sell_close END,
+				ticks = %[1]s.ticks + excluded.ticks
+		`, table), itemID, mode, c.Timestamp.UTC().Format(TimestampLayout),
+			c.BuyOpen, c.BuyHigh, c.BuyLow, c.BuyClose,
+			c.SellOpen, c.SellHigh, c.SellLow, c.SellClose, c.Ticks)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compacted reports whether raw ticks of an item at time at have already been
+// rolled into candles. Compaction only rolls up whole days, so a daily candle
+// on or after at's day means that day's ticks are gone from item_prices.
+func Compacted(ctx context.Context, q Querier, mode string, itemID int, at time.Time) (bool, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT 1 FROM item_candles_1d
+		WHERE item_id = ? AND game_mode = ? AND timestamp >= ?
+		LIMIT 1
+	`, itemID, mode, at.UTC().Truncate(24*time.Hour).Format(TimestampLayout))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+// CompactedItems returns which of the given items in one game mode have had
+// raw quotes rolled into candles, in ascending order
+func CompactedItems(ctx context.Context, mode string, itemIDs []int) ([]int, error) {
+	wanted := make(map[int]bool, len(itemIDs))
+	for _, id := range itemIDs {
+		wanted[id] = true
+	}
+
+	rows, err := ReadDB.QueryContext(ctx, `
+		SELECT DISTINCT item_id FROM item_candles_1d WHERE game_mode = ? ORDER BY item_id
+	`, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var compacted []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if wanted[id] {
+			compacted = append(compacted, id)
+		}
+	}
+	return compacted, rows.Err()
+}
@@ -1,64 +1,227 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// DB is the writer pool; everything that writes uses it. On SQLite it holds a
+// single connection, so writes queue in Go rather than fighting over the lock.
 var DB *sql.DB
 
-func InitDB() {
+// ReadDB is a pool of read-only connections for the HTTP handlers and other
+// queries that don't need to see uncommitted writes. With WAL enabled readers
+// never wait for the writer, so API latency stays flat during a fetch cycle.
+var ReadDB *sql.DB
+
+// Dialect is the SQL engine Open connected to. Each has its own migrations,
+// with the same versions, and every other query is written in SQL both accept.
+var Dialect = DialectSQLite
+
+// The supported values of Dialect
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// Options configures the database connections
+type Options struct {
+	// DSN is the SQLite database file, as a path or a file: URI with extra
+	// parameters, or a postgres:// URL
+	DSN string
+	// BusyTimeout is how long a SQLite connection waits for a lock before failing
+	BusyTimeout time.Duration
+	// WAL switches a SQLite database to write-ahead logging
+	WAL bool
+	// MaxReaders bounds the read-only pool
+	MaxReaders int
+}
+
+// DefaultOptions opens flips.db in the working directory in WAL mode
+var DefaultOptions = Options{
+	DSN:         "flips.db",
+	BusyTimeout: 5 * time.Second,
+	WAL:         true,
+	MaxReaders:  runtime.NumCPU(),
+}
+
+// InitDB opens the database and migrates it to the latest schema. Any migration
+// failure is fatal, so the server never runs against a half-upgraded schema.
+func InitDB(opts Options) {
+	Open(opts)
+
+	if _, err := Migrate(context.Background()); err != nil {
+		log.Fatalf("Database migration failed: %v", err)
+	}
+}
+
+// Open opens the writer and reader pools without touching the schema. A
+// postgres:// or postgresql:// DSN connects to PostgreSQL; anything else is a
+// SQLite file.
+func Open(opts Options) {
+	var writer, reader *sql.DB
 	var err error
-	DB, err = sql.Open("sqlite3", "flips.db")
+	if isPostgresDSN(opts.DSN) {
+		Dialect = DialectPostgres
+		writer, reader, err = openPostgresPools(opts)
+	} else {
+		Dialect = DialectSQLite
+		writer, reader, err = openSQLitePools(opts)
+	}
+	if err != nil {
+		log.Fatalf("Error opening database %s: %v", redactDSN(opts.DSN), err)
+	}
+
+	if opts.MaxReaders > 0 {
+		reader.SetMaxOpenConns(opts.MaxReaders)
+		reader.SetMaxIdleConns(opts.MaxReaders)
+	}
+
+	DB, ReadDB = writer, reader
+}
+
+func openSQLitePools(opts Options) (*sql.DB, *sql.DB, error) {
+	writer, err := sql.Open("sqlite3", connectionDSN(opts, false))
+	if err != nil {
+		return nil, nil, err
+	}
+	writer.SetMaxOpenConns(1)
+	// Connect now so the file exists and is in WAL mode before readers open it
+	if err := writer.Ping(); err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := sql.Open("sqlite3", connectionDSN(opts, true))
+	return writer, reader, err
+}
+
+// openPostgresPools connects both pools to the server. The server handles
+// concurrent writers itself, so the writer pool is not limited to one
+// connection, and the SQLite busy timeout and journal options do not apply.
+func openPostgresPools(opts Options) (*sql.DB, *sql.DB, error) {
+	writer, err := openPostgres(opts.DSN, false)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Create table for storing item prices with moving averages
-	createTable := `
-CREATE TABLE IF NOT EXISTS item_prices (
-    id INTEGER PRIMARY KEY,
-    item_id INTEGER,
-    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-    buy_price INTEGER,
-    sell_price INTEGER
-);
-
-CREATE TABLE IF NOT EXISTS item_analytics (
-    item_id INTEGER PRIMARY KEY,
-    sma5_buy REAL,
-    sma5_sell REAL,
-    rsi_14 REAL DEFAULT 0,
-    macd_line REAL DEFAULT 0,
-    macd_signal REAL DEFAULT 0,
-    macd_hist REAL DEFAULT 0,
-    last_updated DATETIME DEFAULT CURRENT_TIMESTAMP
-);`
-	_, err = DB.Exec(createTable)
+		return nil, nil, err
+	}
+	if err := writer.Ping(); err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := openPostgres(opts.DSN, true)
+	return writer, reader, err
+}
+
+// OpenReadOnly opens a read-only pool on another database, such as a second
+// instance's file to merge history from. Its schema is not migrated.
+func OpenReadOnly(dsn string) (*sql.DB, error) {
+	if isPostgresDSN(dsn) {
+		return openPostgres(dsn, true)
+	}
+	opts := DefaultOptions
+	opts.DSN = dsn
+	db, err := sql.Open("sqlite3", connectionDSN(opts, true))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// redactDSN hides the password of a connection URL so it can be logged
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return dsn
+}
+
+// Close closes both pools. Closing the last connection checkpoints the WAL, so
+// a clean shutdown leaves no -wal or -shm files behind.
+func Close() {
+	if ReadDB != nil {
+		ReadDB.Close()
+	}
+	if DB != nil {
+		DB.Close()
+	}
+}
+
+// connectionDSN adds the busy timeout, foreign keys and journal settings to the
+// configured DSN. Readers open the file read-only and leave the journal mode to
+// the writer; the writer takes its lock when a transaction begins, so two
+// transactions never deadlock upgrading from a read lock.
+func connectionDSN(opts Options, readOnly bool) string {
+	dsn := opts.DSN
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+
+	params := url.Values{}
+	params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	params.Set("_foreign_keys", "on")
+	if readOnly {
+		params.Set("mode", "ro")
+	} else {
+		params.Set("_txlock", "immediate")
+		if opts.WAL {
+			params.Set("_journal_mode", "WAL")
+			params.Set("_synchronous", "NORMAL")
+		}
 	}
 
-	// Quick migration check: Add columns if they don't exist (primitive migration)
-	// In production we'd use a real migration tool.
-	// We'll ignore errors assuming columns might exist
-	DB.Exec("ALTER TABLE item_analytics ADD COLUMN rsi_14 REAL DEFAULT 0;")
-	DB.Exec("ALTER TABLE item_analytics ADD COLUMN macd_line REAL DEFAULT 0;")
-	DB.Exec("ALTER TABLE item_analytics ADD COLUMN macd_signal REAL DEFAULT 0;")
-	DB.Exec("ALTER TABLE item_analytics ADD COLUMN macd_hist REAL DEFAULT 0;")
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + params.Encode()
+}
+
+// AverageTables maps each supported averaging resolution of the prices API to
+// the table its buckets are stored in
+var AverageTables = map[string]string{
+	"5m":  "item_averages_5m",
+	"1h":  "item_averages_1h",
+	"24h": "item_averages_24h",
+}
+
+// DefaultGameMode is the main game's market, which rows stored before game
+// modes existed belong to
+const DefaultGameMode = "osrs"
+
+// GameModes lists the markets served by the prices API, each under its own
+// path prefix: the main game, Deadman Mode and Fresh Start Worlds
+var GameModes = map[string]bool{"osrs": true, "dmm": true, "fsw": true}
+
+// TimestampLayout matches the format SQLite uses for CURRENT_TIMESTAMP, so
+// explicitly inserted times sort and compare correctly against defaults.
+const TimestampLayout = "2006-01-02 15:04:05"
+
+// FormatTimestamp converts a unix timestamp from the prices API into the
+// UTC text form stored in the timestamp columns.
+func FormatTimestamp(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(TimestampLayout)
 }
 
-func CalculateSMA5(itemID int) (float64, float64, error) {
+func CalculateSMA5(ctx context.Context, mode string, itemID int) (float64, float64, error) {
 	query := `
 		SELECT buy_price, sell_price FROM item_prices 
-		WHERE item_id = ? 
-		ORDER BY timestamp DESC 
+		WHERE item_id = ? AND game_mode = ?
+		ORDER BY timestamp DESC, id DESC
 		LIMIT 5;
 	`
 
-	rows, err := DB.Query(query, itemID)
+	rows, err := ReadDB.QueryContext(ctx, query, itemID, mode)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -84,59 +247,160 @@ func CalculateSMA5(itemID int) (float64, float64, error) {
 	return totalBuy / float64(count), totalSell / float64(count), nil
 }
 
-func UpdateItemAnalytics(itemID int) error {
-	// Calculate SMA5
-	smaBuy, smaSell, err := CalculateSMA5(itemID)
+// Execer is satisfied by both *sql.DB and *sql.Tx, so writes can join a
+// caller's transaction
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Querier is satisfied by both *sql.DB and *sql.Tx, so reads can see a
+// caller's uncommitted writes
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ItemAnalytics holds the latest indicator values for an item in one game mode
+type ItemAnalytics struct {
+	ItemID   int
+	GameMode string
+	SMA5Buy  float64
+	SMA5Sell float64
+
+	// RSI and MACD of each indicator series
+	Buy    Indicators
+	Sell   Indicators
+	Mid    Indicators
+	Spread Indicators
+}
+
+// Series returns the indicators of one series, or nil for an unknown series
+func (a *ItemAnalytics) Series(series string) *Indicators {
+	switch series {
+	case SeriesBuy:
+		return &a.Buy
+	case SeriesSell:
+		return &a.Sell
+	case SeriesMid:
+		return &a.Mid
+	case SeriesSpread:
+		return &a.Spread
+	}
+	return nil
+}
+
+// setIndicators copies the current RSI and MACD of every series from state
+func (a *ItemAnalytics) setIndicators(state *IndicatorState) {
+	for _, series := range IndicatorSeries {
+		*a.Series(series) = state.Indicators(series)
+	}
+}
+
+// RebuildItemAnalytics recomputes an item's analytics and indicator state
+// from its whole stored history and stores both. Only raw quotes are folded
+// in, so for an item with quotes rolled into candles (see CompactedItems) the
+// indicators restart at its oldest remaining quote.
+func RebuildItemAnalytics(ctx context.Context, mode string, itemID int) error {
+	analytics, state, err := ComputeItemAnalytics(ctx, mode, IndicatorState{ItemID: itemID, GameMode: mode})
 	if err != nil {
 		return err
 	}
-
-	// Calculate Technical Indicators
-	// Need more history for valid RSI/MACD
-	rows, err := DB.Query(`SELECT buy_price FROM item_prices WHERE item_id = ? ORDER BY timestamp ASC`, itemID)
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+	if err := StoreItemAnalytics(ctx, tx, analytics); err != nil {
+		return err
+	}
+	if err := StoreIndicatorState(ctx, tx, state); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ComputeItemAnalytics folds the quotes stored after an item's indicator state
+// into it and returns the item's analytics with the advanced state, on the read
+// pool without writing anything, so it can run concurrently for many items. An
+// empty state folds in the whole history.
+func ComputeItemAnalytics(ctx context.Context, mode string, state IndicatorState) (ItemAnalytics, IndicatorState, error) {
+	analytics := ItemAnalytics{ItemID: state.ItemID, GameMode: mode}
+
+	// Calculate SMA5
+	smaBuy, smaSell, err := CalculateSMA5(ctx, mode, state.ItemID)
+	if err != nil {
+		return analytics, state, err
+	}
+	analytics.SMA5Buy, analytics.SMA5Sell = smaBuy, smaSell
+
+	// Only quotes after the state's position, which the index finds directly
+	rows, err := ReadDB.QueryContext(ctx, `
+		SELECT id, timestamp, buy_price, sell_price FROM item_prices
+		WHERE item_id = ? AND game_mode = ? AND timestamp >= ? AND (timestamp > ? OR id > ?)
+		ORDER BY timestamp ASC, id ASC
+	`, state.ItemID, mode, state.LastTimestamp.UTC().Format(TimestampLayout),
+		state.LastTimestamp.UTC().Format(TimestampLayout), state.LastID)
+	if err != nil {
+		return analytics, state, err
+	}
 	defer rows.Close()
 
-	var prices []float64
 	for rows.Next() {
-		var p int
-		rows.Scan(&p)
-		prices = append(prices, float64(p))
+		var buy, sell int
+		if err := rows.Scan(&state.LastID, &state.LastTimestamp, &buy, &sell); err != nil {
+			return analytics, state, err
+		}
+		state.Add(float64(buy), float64(sell))
+	}
+	if err := rows.Err(); err != nil {
+		return analytics, state, err
 	}
 
-	rsi := 0.0
-	macdLine, macdSignal, macdHist := 0.0, 0.0, 0.0
+	analytics.setIndicators(&state)
+	return analytics, state, nil
+}
 
-	if len(prices) > 14 {
-		rsiSeries := CalculateRSIFromHistory(prices, 14)
-		rsi = rsiSeries[len(rsiSeries)-1]
-	}
+// AnalyticsFromPrices calculates SMA5, RSI and MACD from an item's buy and
+// sell price history, oldest first. SMA5 averages the last five quotes of each
+// side; RSI and MACD are calculated for every series and stay 0 until there is
+// enough history for them.
+func AnalyticsFromPrices(mode string, itemID int, buys, sells []float64) ItemAnalytics {
+	analytics := ItemAnalytics{ItemID: itemID, GameMode: mode}
 
-	if len(prices) > 26 {
-		mLine, mSig, mHist := CalculateMACD(prices, 12, 26, 9)
-		if len(mLine) > 0 {
-			macdLine = mLine[len(mLine)-1]
-			macdSignal = mSig[len(mSig)-1]
-			macdHist = mHist[len(mHist)-1]
+	// Calculate SMA5
+	if n := len(buys); n > 0 {
+		first := n - 5
+		if first < 0 {
+			first = 0
+		}
+		for i := first; i < n; i++ {
+			analytics.SMA5Buy += buys[i]
+			analytics.SMA5Sell += sells[i]
 		}
+		analytics.SMA5Buy /= float64(n - first)
+		analytics.SMA5Sell /= float64(n - first)
 	}
 
-	// Update or insert into item_analytics
-	_, err = DB.Exec(`
-        INSERT INTO item_analytics (item_id, sma5_buy, sma5_sell, rsi_14, macd_line, macd_signal, macd_hist)
-        VALUES (?, ?, ?, ?, ?, ?, ?)
-        ON CONFLICT(item_id) DO UPDATE SET
-        sma5_buy = ?,
-        sma5_sell = ?,
-        rsi_14 = ?,
-        macd_line = ?,
-        macd_signal = ?,
-        macd_hist = ?,
+	state := IndicatorState{ItemID: itemID, GameMode: mode}
+	for i := range buys {
+		state.Add(buys[i], sells[i])
+	}
+	analytics.setIndicators(&state)
+	return analytics
+}
+
+// StoreItemAnalytics updates or inserts an item's row in item_analytics for
+// its game mode
+func StoreItemAnalytics(ctx context.Context, exec Execer, a ItemAnalytics) error {
+	args := append([]interface{}{a.ItemID, a.GameMode, a.SMA5Buy, a.SMA5Sell}, a.indicatorValues()...)
+	_, err := exec.ExecContext(ctx, `
+        INSERT INTO item_analytics (item_id, game_mode, sma5_buy, sma5_sell, `+indicatorColumnList+`)
+        VALUES (?, ?, ?, ?, `+indicatorPlaceholders+`)
+        ON CONFLICT(item_id, game_mode) DO UPDATE SET
+        sma5_buy = excluded.sma5_buy,
+        sma5_sell = excluded.sma5_sell,
+        `+indicatorUpdates+`,
         last_updated = CURRENT_TIMESTAMP
-    `, itemID, smaBuy, smaSell, rsi, macdLine, macdSignal, macdHist,
-		smaBuy, smaSell, rsi, macdLine, macdSignal, macdHist)
+    `, args...)
 
 	return err
 }
@@ -0,0 +1,379 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PriceRow is an item_prices row as it is exported and imported. Trade times
+// are nil for a side that has never traded.
+type PriceRow struct {
+	ItemID    int        `json:"item_id" parquet:"item_id"`
+	GameMode  string     `json:"game_mode" parquet:"game_mode"`
+	Timestamp time.Time  `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	BuyPrice  int        `json:"buy_price" parquet:"buy_price"`
+	SellPrice int        `json:"sell_price" parquet:"sell_price"`
+	BuyTime   *time.Time `json:"buy_time" parquet:"buy_time"`
+	SellTime  *time.Time `json:"sell_time" parquet:"sell_time"`
+	Quality   string     `json:"quality" parquet:"quality"`
+}
+
+// AnalyticsRow is an item_analytics row as it is exported and imported. The
+// unsuffixed RSI and MACD columns are those of the buy price.
+type AnalyticsRow struct {
+	ItemID           int       `json:"item_id" parquet:"item_id"`
+	GameMode         string    `json:"game_mode" parquet:"game_mode"`
+	SMA5Buy          float64   `json:"sma5_buy" parquet:"sma5_buy"`
+	SMA5Sell         float64   `json:"sma5_sell" parquet:"sma5_sell"`
+	RSI              float64   `json:"rsi_14" parquet:"rsi_14"`
+	MACDLine         float64   `json:"macd_line" parquet:"macd_line"`
+	MACDSignal       float64   `json:"macd_signal" parquet:"macd_signal"`
+	MACDHist         float64   `json:"macd_hist" parquet:"macd_hist"`
+	RSISell          float64   `json:"rsi_14_sell" parquet:"rsi_14_sell"`
+	MACDLineSell     float64   `json:"macd_line_sell" parquet:"macd_line_sell"`
+	MACDSignalSell   float64   `json:"macd_signal_sell" parquet:"macd_signal_sell"`
+	MACDHistSell     float64   `json:"macd_hist_sell" parquet:"macd_hist_sell"`
+	RSIMid           float64   `json:"rsi_14_mid" parquet:"rsi_14_mid"`
+	MACDLineMid      float64   `json:"macd_line_mid" parquet:"macd_line_mid"`
+	MACDSignalMid    float64   `json:"macd_signal_mid" parquet:"macd_signal_mid"`
+	MACDHistMid      float64   `json:"macd_hist_mid" parquet:"macd_hist_mid"`
+	RSISpread        float64   `json:"rsi_14_spread" parquet:"rsi_14_spread"`
+	MACDLineSpread   float64   `json:"macd_line_spread" parquet:"macd_line_spread"`
+	MACDSignalSpread float64   `json:"macd_signal_spread" parquet:"macd_signal_spread"`
+	MACDHistSpread   float64   `json:"macd_hist_spread" parquet:"macd_hist_spread"`
+	LastUpdated      time.Time `json:"last_updated" parquet:"last_updated,timestamp(millisecond)"`
+}
+
+// indicatorFields returns pointers to the row's indicators in the column order
+// of ItemAnalytics.indicatorFields
+func (r *AnalyticsRow) indicatorFields() []interface{} {
+	return []interface{}{
+		&r.RSI, &r.MACDLine, &r.MACDSignal, &r.MACDHist,
+		&r.RSISell, &r.MACDLineSell, &r.MACDSignalSell, &r.MACDHistSell,
+		&r.RSIMid, &r.MACDLineMid, &r.MACDSignalMid, &r.MACDHistMid,
+		&r.RSISpread, &r.MACDLineSpread, &r.MACDSignalSpread, &r.MACDHistSpread,
+	}
+}
+
+// analyticsRow converts analytics to their export row
+func analyticsRow(a ItemAnalytics, lastUpdated time.Time) AnalyticsRow {
+	r := AnalyticsRow{ItemID: a.ItemID, GameMode: a.GameMode, SMA5Buy: a.SMA5Buy, SMA5Sell: a.SMA5Sell, LastUpdated: lastUpdated}
+	fields := r.indicatorFields()
+	for i, value := range a.indicatorValues() {
+		*fields[i].(*float64) = value.(float64)
+	}
+	return r
+}
+
+// CandleRow is a row of one of the candle tables as it is exported and imported
+type CandleRow struct {
+	ItemID    int       `json:"item_id" parquet:"item_id"`
+	GameMode  string    `json:"game_mode" parquet:"game_mode"`
+	Timestamp time.Time `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	BuyOpen   int       `json:"buy_open" parquet:"buy_open"`
+	BuyHigh   int       `json:"buy_high" parquet:"buy_high"`
+	BuyLow    int       `json:"buy_low" parquet:"buy_low"`
+	BuyClose  int       `json:"buy_close" parquet:"buy_close"`
+	SellOpen  int       `json:"sell_open" parquet:"sell_open"`
+	SellHigh  int       `json:"sell_high" parquet:"sell_high"`
+	SellLow   int       `json:"sell_low" parquet:"sell_low"`
+	SellClose int       `json:"sell_close" parquet:"sell_close"`
+	Ticks     int       `json:"ticks" parquet:"ticks"`
+}
+
+// ExportFilter selects the rows of an export: one game mode, optionally
+// limited to some items and to times in [Since, Until). Zero times leave that
+// end of the range open.
+type ExportFilter struct {
+	Mode    string
+	ItemIDs []int // Every item when empty
+	Since   time.Time
+	Until   time.Time
+}
+
+// where builds the WHERE clause selecting the filtered rows, with timeColumn
+// as the column the time range applies to
+func (f ExportFilter) where(timeColumn string) (string, []interface{}) {
+	conditions := []string{"game_mode = ?"}
+	args := []interface{}{f.Mode}
+	if len(f.ItemIDs) > 0 {
+		conditions = append(conditions, "item_id IN (?"+strings.Repeat(", ?", len(f.ItemIDs)-1)+")")
+		for _, id := range f.ItemIDs {
+			args = append(args, id)
+		}
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, timeColumn+" >= ?")
+		args = append(args, f.Since.UTC().Format(TimestampLayout))
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, timeColumn+" < ?")
+		args = append(args, f.Until.UTC().Format(TimestampLayout))
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// matches reports whether a row of the given item and time passes the filter
+func (f ExportFilter) matches(mode string, itemID int, t time.Time) bool {
+	if mode != f.Mode {
+		return false
+	}
+	if !f.Since.IsZero() && t.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !t.Before(f.Until) {
+		return false
+	}
+	if len(f.ItemIDs) == 0 {
+		return true
+	}
+	for _, id := range f.ItemIDs {
+		if id == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SQLStore) ExportPrices(ctx context.Context, filter ExportFilter, fn func(PriceRow) error) error {
+	where, args := filter.where("timestamp")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT item_id, game_mode, timestamp, COALESCE(buy_price, 0), COALESCE(sell_price, 0),
+			buy_time, sell_time, COALESCE(quality, '')
+		FROM item_prices
+		`+where+`
+		ORDER BY item_id, timestamp, id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r PriceRow
+		var buyTime, sellTime sql.NullTime
+		if err := rows.Scan(&r.ItemID, &r.GameMode, &r.Timestamp, &r.BuyPrice, &r.SellPrice,
+			&buyTime, &sellTime, &r.Quality); err != nil {
+			return err
+		}
+		if buyTime.Valid {
+			r.BuyTime = &buyTime.Time
+		}
+		if sellTime.Valid {
+			r.SellTime = &sellTime.Time
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLStore) ExportAnalytics(ctx context.Context, filter ExportFilter, fn func(AnalyticsRow) error) error {
+	where, args := filter.where("last_updated")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT item_id, game_mode, COALESCE(sma5_buy, 0), COALESCE(sma5_sell, 0),
+			`+indicatorSelectList+`,
+			last_updated
+		FROM item_analytics
+		`+where+`
+		ORDER BY item_id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r AnalyticsRow
+		var lastUpdated sql.NullTime
+		fields := append([]interface{}{&r.ItemID, &r.GameMode, &r.SMA5Buy, &r.SMA5Sell}, r.indicatorFields()...)
+		if err := rows.Scan(append(fields, &lastUpdated)...); err != nil {
+			return err
+		}
+		r.LastUpdated = lastUpdated.Time
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLStore) ExportCandles(ctx context.Context, resolution string, filter ExportFilter, fn func(CandleRow) error) error {
+	table, ok := CandleTables[resolution]
+	if !ok {
+		return fmt.Errorf("unknown candle resolution %q", resolution)
+	}
+
+	where, args := filter.where("timestamp")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT item_id, game_mode, timestamp, buy_open, buy_high, buy_low, buy_close,
+			sell_open, sell_high, sell_low, sell_close, ticks
+		FROM `+table+`
+		`+where+`
+		ORDER BY item_id, timestamp
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r CandleRow
+		if err := rows.Scan(&r.ItemID, &r.GameMode, &r.Timestamp, &r.BuyOpen, &r.BuyHigh, &r.BuyLow, &r.BuyClose,
+			&r.SellOpen, &r.SellHigh, &r.SellLow, &r.SellClose, &r.Ticks); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportPrices merges price rows into item_prices in one transaction. A row
+// whose item, game mode, timestamp and trade times are already stored is
+// skipped, so importing the same data twice adds nothing. It returns how many
+// rows were inserted.
+func ImportPrices(ctx context.Context, rows []PriceRow) (int, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	exists, err := tx.PrepareContext(ctx, `
+		SELECT COUNT(*) FROM item_prices
+		WHERE item_id = ? AND game_mode = ? AND buy_time IS NOT DISTINCT FROM ? AND sell_time IS NOT DISTINCT FROM ?
+			AND timestamp = ?
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer exists.Close()
+
+	insert, err := tx.PrepareContext(ctx, `
+		INSERT INTO item_prices (item_id, game_mode, timestamp, buy_price, sell_price, buy_time, sell_time, quality)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	inserted := 0
+	for _, r := range rows {
+		timestamp := r.Timestamp.UTC().Format(TimestampLayout)
+		buyTime, sellTime := nullableTime(r.BuyTime), nullableTime(r.SellTime)
+
+		var stored int
+		if err := exists.QueryRowContext(ctx, r.ItemID, r.GameMode, buyTime, sellTime, timestamp).Scan(&stored); err != nil {
+			return 0, err
+		}
+		if stored > 0 {
+			continue
+		}
+		if _, err := insert.ExecContext(ctx, r.ItemID, r.GameMode, timestamp, r.BuyPrice, r.SellPrice,
+			buyTime, sellTime, r.Quality); err != nil {
+			return 0, err
+		}
+		inserted++
+	}
+	return inserted, tx.Commit()
+}
+
+// ImportAnalytics merges analytics rows into item_analytics in one
+// transaction. An item's stored row is only replaced by a more recently
+// updated one. It returns how many rows were written.
+func ImportAnalytics(ctx context.Context, rows []AnalyticsRow) (int, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO item_analytics (item_id, game_mode, sma5_buy, sma5_sell, `+indicatorColumnList+`, last_updated)
+		VALUES (?, ?, ?, ?, `+indicatorPlaceholders+`, ?)
+		ON CONFLICT(item_id, game_mode) DO UPDATE SET
+			sma5_buy = excluded.sma5_buy,
+			sma5_sell = excluded.sma5_sell,
+			`+indicatorUpdates+`,
+			last_updated = excluded.last_updated
+		WHERE item_analytics.last_updated IS NULL OR item_analytics.last_updated < excluded.last_updated
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	written := 0
+	for _, r := range rows {
+		args := []interface{}{r.ItemID, r.GameMode, r.SMA5Buy, r.SMA5Sell}
+		for _, field := range r.indicatorFields() {
+			args = append(args, *field.(*float64))
+		}
+		res, err := stmt.ExecContext(ctx, append(args, r.LastUpdated.UTC().Format(TimestampLayout))...)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			written++
+		}
+	}
+	return written, tx.Commit()
+}
+
+// ImportCandles merges candle rows of one resolution in one transaction.
+// Candles already stored for the same bucket are kept as they are, since
+// merging their ticks again would count them twice. It returns how many
+// candles were inserted.
+func ImportCandles(ctx context.Context, resolution string, rows []CandleRow) (int, error) {
+	table, ok := CandleTables[resolution]
+	if !ok {
+		return 0, fmt.Errorf("unknown candle resolution %q", resolution)
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO `+table+` (item_id, game_mode, timestamp,
+			buy_open, buy_high, buy_low, buy_close,
+			sell_open, sell_high, sell_low, sell_close, ticks)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for _, r := range rows {
+		res, err := stmt.ExecContext(ctx, r.ItemID, r.GameMode, r.Timestamp.UTC().Format(TimestampLayout),
+			r.BuyOpen, r.BuyHigh, r.BuyLow, r.BuyClose,
+			r.SellOpen, r.SellHigh, r.SellLow, r.SellClose, r.Ticks)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			inserted++
+		}
+	}
+	return inserted, tx.Commit()
+}
+
+// nullableTime formats an optional time for a timestamp column, mapping nil to NULL
+func nullableTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(TimestampLayout)
+}
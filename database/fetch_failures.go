@@ -0,0 +1,18 @@
+package database
+
+import "context"
+
+// RecordFetchFailure logs a request to an upstream API that failed after all
+// retries. statusCode is 0 when no HTTP response was received.
+func RecordFetchFailure(ctx context.Context, url string, statusCode, attempts int, message string) error {
+	var status interface{}
+	if statusCode != 0 {
+		status = statusCode
+	}
+
+	_, err := DB.ExecContext(ctx, `
+		INSERT INTO fetch_failures (url, status_code, attempts, error)
+		VALUES (?, ?, ?, ?)
+	`, url, status, attempts, message)
+	return err
+}
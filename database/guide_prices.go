@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// GuidePrice is the official Grand Exchange guide price of an item on one day
+type GuidePrice struct {
+	Date  time.Time
+	Price int
+}
+
+// StoreGuidePrices upserts an item's daily guide prices in one transaction and
+// returns how many rows were written
+func StoreGuidePrices(ctx context.Context, itemID int, prices []GuidePrice) (int, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO guide_prices (item_id, date, price) VALUES (?, ?, ?)
+		ON CONFLICT(item_id, date) DO UPDATE SET price = excluded.price, fetched_at = CURRENT_TIMESTAMP
+		WHERE guide_prices.price != excluded.price
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	stored := 0
+	for _, p := range prices {
+		res, err := stmt.ExecContext(ctx, itemID, p.Date.UTC().Format(TimestampLayout), p.Price)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			stored++
+		}
+	}
+	return stored, tx.Commit()
+}
+
+// GuidePricedItems returns the items with a guide price dated at or after since
+func GuidePricedItems(ctx context.Context, since time.Time) (map[int]bool, error) {
+	rows, err := ReadDB.QueryContext(ctx, `
+		SELECT DISTINCT item_id FROM guide_prices WHERE date >= ?
+	`, since.UTC().Format(TimestampLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[int]bool)
+	for rows.Next() {
+		var itemID int
+		if err := rows.Scan(&itemID); err != nil {
+			return nil, err
+		}
+		items[itemID] = true
+	}
+	return items, rows.Err()
+}
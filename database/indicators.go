@@ -0,0 +1,334 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Indicator periods used for the stored analytics and item history
+const (
+	RSIPeriod  = 14
+	MACDFast   = 12
+	MACDSlow   = 26
+	MACDSignal = 9
+)
+
+// Indicator series: RSI and MACD are calculated over each of these prices
+const (
+	SeriesBuy    = "buy"    // Instant-sell (low) price
+	SeriesSell   = "sell"   // Instant-buy (high) price
+	SeriesMid    = "mid"    // Midpoint of the buy and sell prices
+	SeriesSpread = "spread" // Sell price minus buy price, the flip margin
+)
+
+// IndicatorSeries lists the indicator series, in the order they are stored
+var IndicatorSeries = []string{SeriesBuy, SeriesSell, SeriesMid, SeriesSpread}
+
+// SeriesPrice returns the value a series follows for a quote, and false for an
+// unknown series
+func SeriesPrice(series string, buy, sell float64) (float64, bool) {
+	switch series {
+	case SeriesBuy:
+		return buy, true
+	case SeriesSell:
+		return sell, true
+	case SeriesMid:
+		return (buy + sell) / 2, true
+	case SeriesSpread:
+		return sell - buy, true
+	}
+	return 0, false
+}
+
+// Indicators are the RSI and MACD values of one series
+type Indicators struct {
+	RSI        float64
+	MACDLine   float64
+	MACDSignal float64
+	MACDHist   float64
+}
+
+// IndicatorColumns returns the item_analytics columns holding a series'
+// indicators. The buy series keeps the original unsuffixed names.
+func IndicatorColumns(series string) (rsi, macdLine, macdSignal, macdHist string) {
+	suffix := ""
+	if series != SeriesBuy {
+		suffix = "_" + series
+	}
+	return "rsi_14" + suffix, "macd_line" + suffix, "macd_signal" + suffix, "macd_hist" + suffix
+}
+
+// SQL fragments listing every series' indicator columns, in the order of
+// IndicatorSeries and then RSI, MACD line, signal and histogram
+var (
+	indicatorColumnList   = strings.Join(indicatorColumns(""), ", ")
+	indicatorSelectList   = strings.Join(indicatorColumns("COALESCE(%s, 0)"), ", ")
+	indicatorPlaceholders = strings.TrimSuffix(strings.Repeat("?, ", 4*len(IndicatorSeries)), ", ")
+	indicatorUpdates      = strings.Join(indicatorColumns("%[1]s = excluded.%[1]s"), ",\n        ")
+)
+
+// indicatorColumns returns every series' indicator columns, each formatted
+// into pattern when one is given
+func indicatorColumns(pattern string) []string {
+	var columns []string
+	for _, series := range IndicatorSeries {
+		rsi, line, signal, hist := IndicatorColumns(series)
+		for _, column := range []string{rsi, line, signal, hist} {
+			if pattern != "" {
+				column = fmt.Sprintf(pattern, column)
+			}
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// indicatorValues returns the analytics' indicators in column order
+func (a *ItemAnalytics) indicatorValues() []interface{} {
+	var values []interface{}
+	for _, series := range IndicatorSeries {
+		ind := a.Series(series)
+		values = append(values, ind.RSI, ind.MACDLine, ind.MACDSignal, ind.MACDHist)
+	}
+	return values
+}
+
+// indicatorFields returns pointers to the analytics' indicators in column
+// order, to scan into
+func (a *ItemAnalytics) indicatorFields() []interface{} {
+	var fields []interface{}
+	for _, series := range IndicatorSeries {
+		ind := a.Series(series)
+		fields = append(fields, &ind.RSI, &ind.MACDLine, &ind.MACDSignal, &ind.MACDHist)
+	}
+	return fields
+}
+
+// IndicatorState is the running state behind an item's RSI and MACD in every
+// series, as of the last quote folded in. Adding a quote updates it in
+// constant time and gives the same values as CalculateRSIFromHistory and
+// CalculateMACD over the whole history.
+type IndicatorState struct {
+	ItemID   int
+	GameMode string
+
+	// Position of the last quote folded in, in (timestamp, id) order. Quotes
+	// after it are the ones still to fold in.
+	LastID        int64
+	LastTimestamp time.Time
+
+	Ticks  int // Quotes folded in so far
+	Buy    SeriesState
+	Sell   SeriesState
+	Mid    SeriesState
+	Spread SeriesState
+}
+
+// Add folds the next quote into the state of every series
+func (s *IndicatorState) Add(buy, sell float64) {
+	for _, series := range IndicatorSeries {
+		value, _ := SeriesPrice(series, buy, sell)
+		s.Series(series).add(value, s.Ticks)
+	}
+	s.Ticks++
+}
+
+// Series returns the state of one series, or nil for an unknown series
+func (s *IndicatorState) Series(series string) *SeriesState {
+	switch series {
+	case SeriesBuy:
+		return &s.Buy
+	case SeriesSell:
+		return &s.Sell
+	case SeriesMid:
+		return &s.Mid
+	case SeriesSpread:
+		return &s.Spread
+	}
+	return nil
+}
+
+// Indicators returns the current RSI and MACD of one series
+func (s *IndicatorState) Indicators(series string) Indicators {
+	return s.Series(series).indicators(s.Ticks)
+}
+
+// SeriesState is the running state of one series: Wilder's average gain and
+// loss and the three EMAs. Until a period has seen enough values, its fields
+// hold the running sums its first average is seeded from.
+type SeriesState struct {
+	Last      float64 // The last value folded in
+	AvgGain   float64
+	AvgLoss   float64
+	EMAFast   float64
+	EMASlow   float64
+	EMASignal float64
+}
+
+// add folds the value at index i of the series into the state
+func (s *SeriesState) add(value float64, i int) {
+	if i > 0 {
+		change := value - s.Last
+		var gain, loss float64
+		if change > 0 {
+			gain = change
+		} else {
+			loss = -change
+		}
+		switch {
+		case i < RSIPeriod:
+			s.AvgGain += gain
+			s.AvgLoss += loss
+		case i == RSIPeriod:
+			s.AvgGain = (s.AvgGain + gain) / float64(RSIPeriod)
+			s.AvgLoss = (s.AvgLoss + loss) / float64(RSIPeriod)
+		default:
+			s.AvgGain = ((s.AvgGain * float64(RSIPeriod-1)) + gain) / float64(RSIPeriod)
+			s.AvgLoss = ((s.AvgLoss * float64(RSIPeriod-1)) + loss) / float64(RSIPeriod)
+		}
+	}
+
+	s.EMAFast = emaStep(s.EMAFast, value, i, MACDFast)
+	s.EMASlow = emaStep(s.EMASlow, value, i, MACDSlow)
+
+	// The MACD line is 0 until the slow EMA exists, and the signal line is
+	// the EMA of the line including those zeros, as in CalculateMACD
+	var line float64
+	if i >= MACDSlow-1 {
+		line = s.EMAFast - s.EMASlow
+	}
+	s.EMASignal = emaStep(s.EMASignal, line, i, MACDSignal)
+
+	s.Last = value
+}
+
+// emaStep advances an EMA of the given period by the value at index i: the
+// first period values are summed and averaged, later ones smoothed in
+func emaStep(ema, value float64, i, period int) float64 {
+	switch {
+	case i < period-1:
+		return ema + value
+	case i == period-1:
+		return (ema + value) / float64(period)
+	default:
+		k := 2.0 / float64(period+1)
+		return (value * k) + (ema * (1 - k))
+	}
+}
+
+// indicators returns the RSI, or 0 until RSIPeriod changes are known, and the
+// MACD, or zeros until the slow EMA has more than MACDSlow values, after the
+// given number of values
+func (s *SeriesState) indicators(ticks int) Indicators {
+	var ind Indicators
+	if ticks > RSIPeriod {
+		if s.AvgLoss == 0 {
+			ind.RSI = 100
+		} else {
+			rs := s.AvgGain / s.AvgLoss
+			ind.RSI = 100 - (100 / (1 + rs))
+		}
+	}
+	if ticks > MACDSlow {
+		ind.MACDLine = s.EMAFast - s.EMASlow
+		ind.MACDSignal = s.EMASignal
+		ind.MACDHist = ind.MACDLine - ind.MACDSignal
+	}
+	return ind
+}
+
+// LoadIndicatorState reads an item's stored indicator state from the read
+// pool. An item without one, or without every series, gets an empty state,
+// which folds in its whole history.
+func LoadIndicatorState(ctx context.Context, mode string, itemID int) (IndicatorState, error) {
+	empty := IndicatorState{ItemID: itemID, GameMode: mode}
+	rows, err := ReadDB.QueryContext(ctx, `
+		SELECT series, last_id, last_timestamp, ticks, last_value, avg_gain, avg_loss, ema_fast, ema_slow, ema_signal
+		FROM item_indicator_state
+		WHERE item_id = ? AND game_mode = ?
+	`, itemID, mode)
+	if err != nil {
+		return empty, err
+	}
+	defer rows.Close()
+
+	s := empty
+	found := 0
+	for rows.Next() {
+		var series string
+		var lastID int64
+		var lastTimestamp time.Time
+		var ticks int
+		var state SeriesState
+		if err := rows.Scan(&series, &lastID, &lastTimestamp, &ticks, &state.Last,
+			&state.AvgGain, &state.AvgLoss, &state.EMAFast, &state.EMASlow, &state.EMASignal); err != nil {
+			return empty, err
+		}
+		target := s.Series(series)
+		if target == nil {
+			continue
+		}
+		// Every series must have stopped at the same quote
+		if found > 0 && (lastID != s.LastID || !lastTimestamp.Equal(s.LastTimestamp) || ticks != s.Ticks) {
+			return empty, rows.Err()
+		}
+		s.LastID, s.LastTimestamp, s.Ticks = lastID, lastTimestamp, ticks
+		*target = state
+		found++
+	}
+	if err := rows.Err(); err != nil {
+		return empty, err
+	}
+	if found < len(IndicatorSeries) {
+		return empty, nil
+	}
+	return s, nil
+}
+
+// StoreIndicatorState updates or inserts an item's indicator state, one row
+// per series
+func StoreIndicatorState(ctx context.Context, exec Execer, s IndicatorState) error {
+	for _, series := range IndicatorSeries {
+		state := s.Series(series)
+		if _, err := exec.ExecContext(ctx, `
+			INSERT INTO item_indicator_state (item_id, game_mode, series, last_id, last_timestamp, ticks, last_value,
+				avg_gain, avg_loss, ema_fast, ema_slow, ema_signal)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(item_id, game_mode, series) DO UPDATE SET
+				last_id = excluded.last_id,
+				last_timestamp = excluded.last_timestamp,
+				ticks = excluded.ticks,
+				last_value = excluded.last_value,
+				avg_gain = excluded.avg_gain,
+				avg_loss = excluded.avg_loss,
+				ema_fast = excluded.ema_fast,
+				ema_slow = excluded.ema_slow,
+				ema_signal = excluded.ema_signal
+		`, s.ItemID, s.GameMode, series, s.LastID, s.LastTimestamp.UTC().Format(TimestampLayout), s.Ticks, state.Last,
+			state.AvgGain, state.AvgLoss, state.EMAFast, state.EMASlow, state.EMASignal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PricedItems returns the IDs of the items with stored quotes in a game mode
+func PricedItems(ctx context.Context, mode string) ([]int, error) {
+	rows, err := ReadDB.QueryContext(ctx, `SELECT DISTINCT item_id FROM item_prices WHERE game_mode = ? ORDER BY item_id`, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var itemIDs []int
+	for rows.Next() {
+		var itemID int
+		if err := rows.Scan(&itemID); err != nil {
+			return nil, err
+		}
+		itemIDs = append(itemIDs, itemID)
+	}
+	return itemIDs, rows.Err()
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// recompute returns the indicators of a whole price history the way they were
+// calculated before the running state, with CalculateRSIFromHistory and
+// CalculateMACD
+func recompute(prices []float64) Indicators {
+	var ind Indicators
+	if len(prices) > RSIPeriod {
+		rsi := CalculateRSIFromHistory(prices, RSIPeriod)
+		ind.RSI = rsi[len(rsi)-1]
+	}
+	if len(prices) > MACDSlow {
+		line, signal, hist := CalculateMACD(prices, MACDFast, MACDSlow, MACDSignal)
+		ind.MACDLine, ind.MACDSignal, ind.MACDHist = line[len(line)-1], signal[len(signal)-1], hist[len(hist)-1]
+	}
+	return ind
+}
+
+// closeIndicators compares indicators allowing for floating-point rounding
+func closeIndicators(a, b Indicators) bool {
+	return math.Abs(a.RSI-b.RSI) <= 1e-9 && math.Abs(a.MACDLine-b.MACDLine) <= 1e-9 &&
+		math.Abs(a.MACDSignal-b.MACDSignal) <= 1e-9 && math.Abs(a.MACDHist-b.MACDHist) <= 1e-9
+}
+
+func TestIndicatorStateMatchesRecompute(t *testing.T) {
+	ctx := context.Background()
+	openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+
+	const n = 70
+	tests := []struct {
+		name  string
+		quote func(i int) (buy, sell float64)
+	}{
+		{"rising", func(i int) (float64, float64) { return 100 + 3*float64(i), 110 + 3*float64(i) + float64(i%4) }},
+		{"choppy", func(i int) (float64, float64) {
+			return 1000 + 40*math.Sin(float64(i)/3), 1060 + 25*math.Cos(float64(i)/2)
+		}},
+		{"flat", func(i int) (float64, float64) { return 500, 520 }},
+		{"crossed", func(i int) (float64, float64) { return 200 + float64(i%9), 205 - float64(i%7) }},
+	}
+	chunks := []int{1, 5, 13, 27, n}
+
+	for item, tt := range tests {
+		var buys, sells []float64
+		for i := 0; i < n; i++ {
+			buy, sell := tt.quote(i)
+			buys, sells = append(buys, buy), append(sells, sell)
+		}
+
+		for _, chunk := range chunks {
+			itemID := 930000 + 100*item + chunk
+			state := IndicatorState{ItemID: itemID, GameMode: "osrs"}
+			for i := range buys {
+				state.Add(buys[i], sells[i])
+				state.LastID, state.LastTimestamp = int64(i+1), time.Date(2026, 3, 14, 12, i, 0, 0, time.UTC)
+
+				for _, series := range IndicatorSeries {
+					var prices []float64
+					for j := 0; j <= i; j++ {
+						price, _ := SeriesPrice(series, buys[j], sells[j])
+						prices = append(prices, price)
+					}
+					if got, want := state.Indicators(series), recompute(prices); !closeIndicators(got, want) {
+						t.Errorf("%s, chunks of %d, %s after %d quotes: %+v, want %+v", tt.name, chunk, series, i+1, got, want)
+					}
+				}
+
+				// Resume from the stored state at the end of each chunk
+				if (i+1)%chunk != 0 {
+					continue
+				}
+				if err := StoreIndicatorState(ctx, DB, state); err != nil {
+					t.Fatal(err)
+				}
+				loaded, err := LoadIndicatorState(ctx, "osrs", itemID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if loaded.Ticks != state.Ticks || loaded.LastID != state.LastID || !loaded.LastTimestamp.Equal(state.LastTimestamp) {
+					t.Fatalf("%s: loaded state at quote %d after %d ticks, want quote %d", tt.name, loaded.LastID, loaded.Ticks, i+1)
+				}
+				state = loaded
+			}
+		}
+	}
+}
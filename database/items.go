@@ -1,70 +1,199 @@
 package database
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Item is an entry of the item catalog, as published by the wiki's /mapping endpoint
 type Item struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Members     bool   `json:"members"`
-	Tradeable   bool   `json:"tradeable"`
-	TradeableGE bool   `json:"tradeable_on_ge"`
-	Incomplete  bool   `json:"incomplete"`
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Examine  string `json:"examine"`
+	Members  bool   `json:"members"`
+	Limit    int    `json:"limit"`    // GE buy limit per 4 hours, 0 if unknown
+	HighAlch int    `json:"highalch"` // High Level Alchemy value
+	LowAlch  int    `json:"lowalch"`  // Low Level Alchemy value
+	Value    int    `json:"value"`    // Store value the alch values derive from
+	Icon     string `json:"icon"`
 }
 
-type ItemsData map[string]Item
+type ItemsData map[int]Item
 
-var itemsCache ItemsData
+// ItemCatalog is the in-memory item catalog shared by the jobs and the API.
+// Readers see an immutable snapshot and never block; Load builds a new one
+// and swaps it in atomically, so a lookup never sees a half-loaded catalog.
+type ItemCatalog struct {
+	snapshot atomic.Pointer[catalogSnapshot]
+	loadMu   sync.Mutex // Serializes loads, so an older read never replaces a newer one
+}
 
-func LoadItemsData() error {
-	if itemsCache != nil {
-		return nil // Already loaded
-	}
+// catalogSnapshot is one loaded version of the catalog
+type catalogSnapshot struct {
+	items    ItemsData
+	ids      []int // Sorted item IDs
+	loadedAt time.Time
+}
+
+// Catalog is the item catalog loaded from the item_catalog table
+var Catalog = NewItemCatalog()
+
+// NewItemCatalog creates an empty item catalog
+func NewItemCatalog() *ItemCatalog {
+	c := &ItemCatalog{}
+	c.snapshot.Store(&catalogSnapshot{items: make(ItemsData)})
+	return c
+}
 
-	data, err := os.ReadFile("items.json")
+// Load reads the item_catalog table and swaps it in as the catalog. An empty
+// table is an error and leaves the current catalog in place.
+func (c *ItemCatalog) Load(ctx context.Context) error {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+
+	rows, err := ReadDB.QueryContext(ctx, `
+		SELECT id, name, examine, members, buy_limit, highalch, lowalch, value, icon
+		FROM item_catalog
+	`)
 	if err != nil {
-		return fmt.Errorf("error reading items.json: %v", err)
+		return fmt.Errorf("error reading item catalog: %v", err)
 	}
+	defer rows.Close()
 
-	if err := json.Unmarshal(data, &itemsCache); err != nil {
-		return fmt.Errorf("error parsing items.json: %v", err)
+	items := make(ItemsData)
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.ID, &item.Name, &item.Examine, &item.Members, &item.Limit,
+			&item.HighAlch, &item.LowAlch, &item.Value, &item.Icon); err != nil {
+			return fmt.Errorf("error parsing item catalog: %v", err)
+		}
+		items[item.ID] = item
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("item catalog is empty")
 	}
 
+	c.swap(items)
 	return nil
 }
 
-func GetItemName(itemID int) string {
-	if itemsCache == nil {
-		if err := LoadItemsData(); err != nil {
-			return fmt.Sprintf("Item %d", itemID)
-		}
+// swap replaces the catalog with items
+func (c *ItemCatalog) swap(items ItemsData) {
+	ids := make([]int, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
 	}
+	sort.Ints(ids)
+	c.snapshot.Store(&catalogSnapshot{items: items, ids: ids, loadedAt: time.Now()})
+}
+
+// Item returns the catalog entry for an item
+func (c *ItemCatalog) Item(itemID int) (Item, bool) {
+	item, ok := c.snapshot.Load().items[itemID]
+	return item, ok
+}
 
-	if item, exists := itemsCache[fmt.Sprintf("%d", itemID)]; exists {
+// Name returns an item's name, or "Item <id>" for an unknown item
+func (c *ItemCatalog) Name(itemID int) string {
+	if item, ok := c.Item(itemID); ok {
 		return item.Name
 	}
 	return fmt.Sprintf("Item %d", itemID)
 }
 
-func GetAllTradeableItems() []int {
-	if itemsCache == nil {
-		if err := LoadItemsData(); err != nil {
-			return []int{}
-		}
+// IDByName returns the ID of the best case-insensitive name match, or -1
+func (c *ItemCatalog) IDByName(name string) int {
+	return findItemIDByName(c.snapshot.Load().items, name)
+}
+
+// IDs returns the IDs of every item in the catalog, in ascending order
+func (c *ItemCatalog) IDs() []int {
+	return append([]int{}, c.snapshot.Load().ids...)
+}
+
+// Len returns how many items the catalog holds
+func (c *ItemCatalog) Len() int {
+	return len(c.snapshot.Load().items)
+}
+
+// LoadedAt returns when the catalog was last loaded, or the zero time if it
+// never was
+func (c *ItemCatalog) LoadedAt() time.Time {
+	return c.snapshot.Load().loadedAt
+}
+
+// StoreItemCatalog replaces the contents of item_catalog with a fresh /mapping
+// download in one transaction, so items dropped from the mapping go too and
+// readers never see a mix of both, and reloads Catalog from it. An empty list
+// is refused rather than emptying the catalog.
+func StoreItemCatalog(ctx context.Context, items []Item) error {
+	if len(items) == 0 {
+		return fmt.Errorf("refusing to replace the item catalog with no items")
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM item_catalog`); err != nil {
+		return err
 	}
 
-	var tradeableItems []int
-	for _, item := range itemsCache {
-		// Include all tradeable items that can be traded on GE
-		if item.Tradeable && item.TradeableGE {
-			tradeableItems = append(tradeableItems, item.ID)
+	// A repeated ID keeps its last entry
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO item_catalog (id, name, examine, members, buy_limit, highalch, lowalch, value, icon, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET
+		name = excluded.name,
+		examine = excluded.examine,
+		members = excluded.members,
+		buy_limit = excluded.buy_limit,
+		highalch = excluded.highalch,
+		lowalch = excluded.lowalch,
+		value = excluded.value,
+		icon = excluded.icon,
+		updated_at = CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, item := range items {
+		if _, err := stmt.ExecContext(ctx, item.ID, item.Name, item.Examine, item.Members, item.Limit,
+			item.HighAlch, item.LowAlch, item.Value, item.Icon); err != nil {
+			return fmt.Errorf("error storing item %d: %v", item.ID, err)
 		}
 	}
 
-	return tradeableItems
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return Catalog.Load(ctx)
+}
+
+// GetItem returns the catalog entry for an item
+func GetItem(itemID int) (Item, bool) {
+	return Catalog.Item(itemID)
+}
+
+func GetItemName(itemID int) string {
+	return Catalog.Name(itemID)
+}
+
+// GetAllTradeableItems returns every item in the catalog; the /mapping
+// endpoint only lists items that can be traded on the GE
+func GetAllTradeableItems() []int {
+	return Catalog.IDs()
 }
 
 func GetPopularItems() []int {
@@ -72,21 +201,18 @@ func GetPopularItems() []int {
 	return GetAllTradeableItems()
 }
 
-// GetItemIDByName searches for an item ID by its name (case-insensitive)
-// Returns -1 if not found
-// GetItemIDByName searches for an item by name and returns its ID
+// GetItemIDByName searches for an item by name (case-insensitive) and returns its ID
 // Returns -1 if not found
 func GetItemIDByName(name string) int {
-	if itemsCache == nil {
-		if err := LoadItemsData(); err != nil {
-			return -1
-		}
-	}
+	return Catalog.IDByName(name)
+}
 
+// findItemIDByName picks the best case-insensitive name match in items, or -1
+func findItemIDByName(items ItemsData, name string) int {
 	var candidates []Item
 
 	// Collect all matches
-	for _, item := range itemsCache {
+	for _, item := range items {
 		if lowercaseEqual(item.Name, name) {
 			candidates = append(candidates, item)
 		}
@@ -97,10 +223,8 @@ func GetItemIDByName(name string) int {
 	}
 
 	// Filter and sort to find the best match
-	// Priority 1: Tradeable on GE
-	// Priority 2: Not incomplete
-	// Priority 3: Exact name match (case sensitive)
-	// Priority 4: Lowest ID (usually implies original item vs variants)
+	// Priority 1: Exact name match (case sensitive)
+	// Priority 2: Lowest ID (usually implies original item vs variants)
 
 	bestCandidate := candidates[0]
 	bestScore := calculateItemScore(bestCandidate, name)
@@ -119,12 +243,6 @@ func GetItemIDByName(name string) int {
 
 func calculateItemScore(item Item, searchName string) int {
 	score := 0
-	if item.TradeableGE {
-		score += 100
-	}
-	if !item.Incomplete {
-		score += 50
-	}
 	if item.Name == searchName {
 		score += 25
 	}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// catalogItems returns items with the given IDs, named after their version
+func catalogItems(version string, ids ...int) []Item {
+	items := make([]Item, len(ids))
+	for i, id := range ids {
+		items[i] = Item{ID: id, Name: fmt.Sprintf("%s %d", version, id), Limit: id}
+	}
+	return items
+}
+
+func TestStoreItemCatalogReplaces(t *testing.T) {
+	openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+	ctx := context.Background()
+
+	if err := StoreItemCatalog(ctx, catalogItems("old", 1, 2, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := StoreItemCatalog(ctx, catalogItems("new", 2, 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := Catalog.IDs(); len(ids) != 2 || ids[0] != 2 || ids[1] != 4 {
+		t.Errorf("catalog IDs = %v, want [2 4]", ids)
+	}
+	if name := Catalog.Name(2); name != "new 2" {
+		t.Errorf("Name(2) = %q, want the new entry", name)
+	}
+	var stored int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM item_catalog`).Scan(&stored); err != nil || stored != 2 {
+		t.Errorf("item_catalog holds %d rows (%v), want 2", stored, err)
+	}
+}
+
+func TestItemCatalogFailedReloadKeepsSnapshot(t *testing.T) {
+	openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+	ctx := context.Background()
+
+	if err := StoreItemCatalog(ctx, catalogItems("v1", 1, 2, 3)); err != nil {
+		t.Fatal(err)
+	}
+	loadedAt := Catalog.LoadedAt()
+
+	// An empty download is refused and the table keeps its rows
+	if err := StoreItemCatalog(ctx, nil); err == nil {
+		t.Error("storing an empty catalog succeeded")
+	}
+	// A cancelled store rolls back
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := StoreItemCatalog(cancelled, catalogItems("v2", 4)); err == nil {
+		t.Error("storing with a cancelled context succeeded")
+	}
+	// Loading an emptied table fails and keeps the loaded snapshot
+	if _, err := DB.Exec(`DELETE FROM item_catalog`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Catalog.Load(ctx); err == nil {
+		t.Error("loading an empty item_catalog succeeded")
+	}
+
+	if n := Catalog.Len(); n != 3 || Catalog.Name(2) != "v1 2" || !Catalog.LoadedAt().Equal(loadedAt) {
+		t.Errorf("after failed reloads: %d items, Name(2) = %q, loaded at %v (was %v)", n, Catalog.Name(2), Catalog.LoadedAt(), loadedAt)
+	}
+}
+
+// TestItemCatalogConcurrentReload reads the catalog while it is replaced over
+// and over; run with -race. Every read must see one version or the other,
+// never a mix or a partly loaded catalog.
+func TestItemCatalogConcurrentReload(t *testing.T) {
+	openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+	ctx := context.Background()
+
+	var idsA, idsB []int
+	for id := 1; id <= 200; id++ {
+		idsA = append(idsA, id)
+		if id%2 == 0 {
+			idsB = append(idsB, id)
+		}
+	}
+	if err := StoreItemCatalog(ctx, catalogItems("A", idsA...)); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				ids := Catalog.IDs()
+				if len(ids) != len(idsA) && len(ids) != len(idsB) {
+					t.Errorf("read %d IDs, want %d or %d", len(ids), len(idsA), len(idsB))
+					return
+				}
+				if !sort.IntsAreSorted(ids) {
+					t.Error("IDs are not sorted")
+					return
+				}
+				if item, ok := Catalog.Item(2); !ok || item.Limit != 2 ||
+					(!strings.HasPrefix(item.Name, "A ") && !strings.HasPrefix(item.Name, "B ")) {
+					t.Errorf("Item(2) = %+v, %v", item, ok)
+					return
+				}
+				if id := Catalog.IDByName("b 4"); id != 4 && id != -1 {
+					t.Errorf("IDByName(b 4) = %d", id)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		version, ids := "B", idsB
+		if i%2 == 1 {
+			version, ids = "A", idsA
+		}
+		if err := StoreItemCatalog(ctx, catalogItems(version, ids...)); err != nil {
+			t.Error(err)
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// JobRun is one recorded execution of a scheduled job
+type JobRun struct {
+	Job        string `json:"job"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// RecordJobRun stores the outcome of a scheduled job run; runErr is nil on success
+func RecordJobRun(ctx context.Context, job string, start, end time.Time, runErr error) error {
+	var message interface{}
+	if runErr != nil {
+		message = runErr.Error()
+	}
+
+	_, err := DB.ExecContext(ctx, `
+		INSERT INTO job_runs (job, started_at, finished_at, duration_ms, error)
+		VALUES (?, ?, ?, ?, ?)
+	`, job, start.UTC().Format(TimestampLayout), end.UTC().Format(TimestampLayout),
+		end.Sub(start).Milliseconds(), message)
+	return err
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// legacyColumns were added to existing tables with ALTER TABLE before schema
+// migrations existed, so databases from that time may lack any of them
+var legacyColumns = []struct{ table, column string }{
+	{"item_analytics", "rsi_14 REAL DEFAULT 0"},
+	{"item_analytics", "macd_line REAL DEFAULT 0"},
+	{"item_analytics", "macd_signal REAL DEFAULT 0"},
+	{"item_analytics", "macd_hist REAL DEFAULT 0"},
+	{"item_prices", "buy_time DATETIME"},
+	{"item_prices", "sell_time DATETIME"},
+	{"item_prices", "quality TEXT DEFAULT 'unchecked'"},
+	{"item_prices", "game_mode TEXT NOT NULL DEFAULT 'osrs'"},
+	{"price_quarantine", "game_mode TEXT NOT NULL DEFAULT 'osrs'"},
+	{"raw_snapshots", "game_mode TEXT NOT NULL DEFAULT 'osrs'"},
+}
+
+// legacyKeyedTables gained game_mode in their primary key, which SQLite can
+// only do by rebuilding the table. The definitions match the baseline migration.
+var legacyKeyedTables = map[string]string{
+	"item_analytics": `
+CREATE TABLE item_analytics (
+    item_id INTEGER NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    sma5_buy REAL,
+    sma5_sell REAL,
+    rsi_14 REAL DEFAULT 0,
+    macd_line REAL DEFAULT 0,
+    macd_signal REAL DEFAULT 0,
+    macd_hist REAL DEFAULT 0,
+    last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (item_id, game_mode)
+)`,
+	"item_averages_5m":  legacyAveragesTable("item_averages_5m"),
+	"item_averages_1h":  legacyAveragesTable("item_averages_1h"),
+	"item_averages_24h": legacyAveragesTable("item_averages_24h"),
+}
+
+func legacyAveragesTable(table string) string {
+	return fmt.Sprintf(`
+CREATE TABLE %s (
+    item_id INTEGER NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp DATETIME NOT NULL,
+    avg_high_price INTEGER,
+    avg_low_price INTEGER,
+    high_price_volume INTEGER DEFAULT 0,
+    low_price_volume INTEGER DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+)`, table)
+}
+
+// upgradeLegacySchema brings a database created before schema migrations up
+// to the baseline migration's shape, so the baseline can then be recorded as
+// applied. A fresh database is left alone.
+func upgradeLegacySchema(ctx context.Context) error {
+	exists, err := tableExists(ctx, "item_prices")
+	if err != nil || !exists {
+		return err
+	}
+	log.Println("Upgrading database created before schema migrations")
+
+	for _, c := range legacyColumns {
+		exists, err := tableExists(ctx, c.table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue // The baseline migration creates it
+		}
+		name := strings.Fields(c.column)[0]
+		has, err := hasColumn(ctx, c.table, name)
+		if err != nil {
+			return err
+		}
+		if has {
+			continue
+		}
+		if _, err := DB.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", c.table, c.column)); err != nil {
+			return fmt.Errorf("adding %s.%s: %v", c.table, name, err)
+		}
+	}
+
+	for table, create := range legacyKeyedTables {
+		exists, err := tableExists(ctx, table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		has, err := hasColumn(ctx, table, "game_mode")
+		if err != nil {
+			return err
+		}
+		if has {
+			continue
+		}
+		if err := rebuildWithGameMode(ctx, table, create); err != nil {
+			return fmt.Errorf("rebuilding %s: %v", table, err)
+		}
+	}
+	return nil
+}
+
+// rebuildWithGameMode recreates a table so game_mode becomes part of its
+// primary key, copying the existing rows across as main game data
+func rebuildWithGameMode(ctx context.Context, table, create string) error {
+	columns, err := tableColumns(ctx, table)
+	if err != nil {
+		return err
+	}
+	list := strings.Join(columns, ", ")
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	steps := []string{
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s_old", table, table),
+		create,
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s_old", table, list, list, table),
+		fmt.Sprintf("DROP TABLE %s_old", table),
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step); err != nil {
+			return err
+		}
+	}
+	log.Printf("Rebuilt %s with game_mode in its primary key", table)
+	return tx.Commit()
+}
+
+func tableExists(ctx context.Context, table string) (bool, error) {
+	var n int
+	err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n)
+	return n > 0, err
+}
+
+func hasColumn(ctx context.Context, table, column string) (bool, error) {
+	columns, err := tableColumns(ctx, table)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range columns {
+		if c == column {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func tableColumns(ctx context.Context, table string) ([]string, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,390 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store held entirely in memory, for running handlers
+// without a database. It starts empty and is filled with the Add and Set
+// methods; it is safe for concurrent use.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	prices      map[marketKey][]PricePoint
+	candles     map[seriesKey][]Candle
+	averages    map[seriesKey][]Average
+	guidePrices map[int][]GuidePrice
+	quarantine  map[string][]QuarantinedQuote
+	analytics   map[marketKey]ItemAnalytics
+	items       ItemsData
+	failures    []FetchFailure
+	jobRuns     []JobRun
+}
+
+// marketKey identifies an item in one game mode
+type marketKey struct {
+	mode   string
+	itemID int
+}
+
+// seriesKey identifies an item's series of one resolution in one game mode
+type seriesKey struct {
+	mode       string
+	resolution string
+	itemID     int
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		prices:      make(map[marketKey][]PricePoint),
+		candles:     make(map[seriesKey][]Candle),
+		averages:    make(map[seriesKey][]Average),
+		guidePrices: make(map[int][]GuidePrice),
+		quarantine:  make(map[string][]QuarantinedQuote),
+		analytics:   make(map[marketKey]ItemAnalytics),
+		items:       make(ItemsData),
+	}
+}
+
+// AddPrices stores quotes for an item, keeping its history in time order
+func (s *MemoryStore) AddPrices(mode string, itemID int, points ...PricePoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := marketKey{mode, itemID}
+	s.prices[key] = append(s.prices[key], points...)
+	sort.SliceStable(s.prices[key], func(i, j int) bool {
+		return s.prices[key][i].Timestamp.Before(s.prices[key][j].Timestamp)
+	})
+}
+
+// AddCandles stores candles of one resolution for an item, in time order
+func (s *MemoryStore) AddCandles(mode, resolution string, itemID int, candles ...Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := seriesKey{mode, resolution, itemID}
+	s.candles[key] = append(s.candles[key], candles...)
+	sort.SliceStable(s.candles[key], func(i, j int) bool {
+		return s.candles[key][i].Timestamp.Before(s.candles[key][j].Timestamp)
+	})
+}
+
+// AddAverages stores averaged buckets of one resolution for an item, in time order
+func (s *MemoryStore) AddAverages(mode, resolution string, itemID int, averages ...Average) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := seriesKey{mode, resolution, itemID}
+	s.averages[key] = append(s.averages[key], averages...)
+	sort.SliceStable(s.averages[key], func(i, j int) bool {
+		return s.averages[key][i].Timestamp.Before(s.averages[key][j].Timestamp)
+	})
+}
+
+// AddGuidePrices stores guide prices for an item
+func (s *MemoryStore) AddGuidePrices(itemID int, prices ...GuidePrice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guidePrices[itemID] = append(s.guidePrices[itemID], prices...)
+}
+
+// AddQuarantinedQuotes stores quarantined quotes, each newer than the last
+func (s *MemoryStore) AddQuarantinedQuotes(mode string, quotes ...QuarantinedQuote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quarantine[mode] = append(s.quarantine[mode], quotes...)
+}
+
+// SetAnalytics stores analytics, replacing any for the same item and game mode
+func (s *MemoryStore) SetAnalytics(analytics ...ItemAnalytics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range analytics {
+		s.analytics[marketKey{a.GameMode, a.ItemID}] = a
+	}
+}
+
+// SetItems adds items to the catalog, replacing any with the same ID
+func (s *MemoryStore) SetItems(items ...Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range items {
+		s.items[item.ID] = item
+	}
+}
+
+// AddFetchFailures stores failed requests, each newer than the last
+func (s *MemoryStore) AddFetchFailures(failures ...FetchFailure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failures...)
+}
+
+// AddJobRuns stores job runs, each newer than the last
+func (s *MemoryStore) AddJobRuns(runs ...JobRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobRuns = append(s.jobRuns, runs...)
+}
+
+func (s *MemoryStore) PriceHistory(ctx context.Context, mode string, itemID int) ([]PricePoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]PricePoint(nil), s.prices[marketKey{mode, itemID}]...), nil
+}
+
+func (s *MemoryStore) Candles(ctx context.Context, mode, resolution string, itemID int) ([]Candle, error) {
+	if _, ok := CandleTables[resolution]; !ok {
+		return nil, fmt.Errorf("unknown candle resolution %q", resolution)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Candle(nil), s.candles[seriesKey{mode, resolution, itemID}]...), nil
+}
+
+func (s *MemoryStore) Averages(ctx context.Context, mode, resolution string, itemID int) ([]Average, error) {
+	if _, ok := AverageTables[resolution]; !ok {
+		return nil, fmt.Errorf("unknown average resolution %q", resolution)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Average(nil), s.averages[seriesKey{mode, resolution, itemID}]...), nil
+}
+
+func (s *MemoryStore) RecentVolumes(ctx context.Context, mode string, since, until time.Time) (map[int]Volume, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	volumes := make(map[int]Volume)
+	for key, averages := range s.averages {
+		if key.mode != mode || key.resolution != "5m" {
+			continue
+		}
+		for _, a := range averages {
+			if a.Timestamp.Before(since) || !a.Timestamp.Before(until) {
+				continue
+			}
+			v := volumes[key.itemID]
+			v.High += a.HighPriceVolume
+			v.Low += a.LowPriceVolume
+			volumes[key.itemID] = v
+		}
+	}
+	return volumes, nil
+}
+
+func (s *MemoryStore) LatestGuidePrices(ctx context.Context) (map[int]GuidePrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	latest := make(map[int]GuidePrice)
+	for itemID, prices := range s.guidePrices {
+		for _, p := range prices {
+			if current, ok := latest[itemID]; !ok || p.Date.After(current.Date) {
+				latest[itemID] = p
+			}
+		}
+	}
+	return latest, nil
+}
+
+func (s *MemoryStore) QuarantinedQuotes(ctx context.Context, mode string, itemID, limit int) ([]QuarantinedQuote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	quotes := make([]QuarantinedQuote, 0)
+	all := s.quarantine[mode]
+	for i := len(all) - 1; i >= 0 && len(quotes) < limit; i-- {
+		if itemID == 0 || all[i].ItemID == itemID {
+			quotes = append(quotes, all[i])
+		}
+	}
+	return quotes, nil
+}
+
+func (s *MemoryStore) Analytics(ctx context.Context, mode string) ([]ItemAnalytics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var analytics []ItemAnalytics
+	for key, a := range s.analytics {
+		if key.mode == mode {
+			analytics = append(analytics, a)
+		}
+	}
+	sort.Slice(analytics, func(i, j int) bool { return analytics[i].ItemID < analytics[j].ItemID })
+	return analytics, nil
+}
+
+func (s *MemoryStore) AnalyticsAsOf(ctx context.Context, mode string, asOf time.Time) ([]ItemAnalytics, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var oldest time.Time
+	for key, points := range s.prices {
+		if key.mode == mode && len(points) > 0 && (oldest.IsZero() || points[0].Timestamp.Before(oldest)) {
+			oldest = points[0].Timestamp
+		}
+	}
+	if asOf.Before(oldest) {
+		return nil, false, fmt.Errorf("%w, the oldest is from %s", ErrBeforeHistory, oldest.UTC().Format(time.RFC3339))
+	}
+
+	compacted := false
+	for key, candles := range s.candles {
+		if key.mode == mode && key.resolution == "1d" && len(candles) > 0 {
+			compacted = true
+		}
+	}
+
+	var analytics []ItemAnalytics
+	for key, points := range s.prices {
+		if key.mode != mode {
+			continue
+		}
+		var buys, sells []float64
+		for _, p := range points {
+			if p.Timestamp.After(asOf) {
+				break
+			}
+			buys = append(buys, float64(p.BuyPrice))
+			sells = append(sells, float64(p.SellPrice))
+		}
+		if len(buys) > 0 {
+			analytics = append(analytics, AnalyticsFromPrices(mode, key.itemID, buys, sells))
+		}
+	}
+	sort.Slice(analytics, func(i, j int) bool { return analytics[i].ItemID < analytics[j].ItemID })
+	return analytics, compacted, nil
+}
+
+func (s *MemoryStore) Item(itemID int) (Item, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[itemID]
+	return item, ok
+}
+
+func (s *MemoryStore) ItemName(itemID int) string {
+	if item, ok := s.Item(itemID); ok {
+		return item.Name
+	}
+	return fmt.Sprintf("Item %d", itemID)
+}
+
+func (s *MemoryStore) ItemIDByName(name string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return findItemIDByName(s.items, name)
+}
+
+func (s *MemoryStore) TrackedItems() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]int, 0, len(s.items))
+	for id := range s.items {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (s *MemoryStore) FetchFailures(ctx context.Context, limit int) ([]FetchFailure, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	failures := make([]FetchFailure, 0)
+	for i := len(s.failures) - 1; i >= 0 && len(failures) < limit; i-- {
+		failures = append(failures, s.failures[i])
+	}
+	return failures, nil
+}
+
+func (s *MemoryStore) JobRuns(ctx context.Context, job string, limit int) ([]JobRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	runs := make([]JobRun, 0)
+	for i := len(s.jobRuns) - 1; i >= 0 && len(runs) < limit; i-- {
+		if s.jobRuns[i].Job == job {
+			runs = append(runs, s.jobRuns[i])
+		}
+	}
+	return runs, nil
+}
+
+func (s *MemoryStore) ExportPrices(ctx context.Context, filter ExportFilter, fn func(PriceRow) error) error {
+	s.mu.RLock()
+	var rows []PriceRow
+	for key, points := range s.prices {
+		for _, p := range points {
+			if filter.matches(key.mode, key.itemID, p.Timestamp) {
+				rows = append(rows, PriceRow{ItemID: key.itemID, GameMode: key.mode,
+					Timestamp: p.Timestamp, BuyPrice: p.BuyPrice, SellPrice: p.SellPrice})
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].ItemID != rows[j].ItemID {
+			return rows[i].ItemID < rows[j].ItemID
+		}
+		return rows[i].Timestamp.Before(rows[j].Timestamp)
+	})
+	for _, r := range rows {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ExportAnalytics(ctx context.Context, filter ExportFilter, fn func(AnalyticsRow) error) error {
+	analytics, err := s.Analytics(ctx, filter.Mode)
+	if err != nil {
+		return err
+	}
+	// Analytics held in memory carry no update time, so the time range doesn't apply
+	undated := ExportFilter{Mode: filter.Mode, ItemIDs: filter.ItemIDs}
+	for _, a := range analytics {
+		if !undated.matches(a.GameMode, a.ItemID, time.Time{}) {
+			continue
+		}
+		if err := fn(analyticsRow(a, time.Time{})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ExportCandles(ctx context.Context, resolution string, filter ExportFilter, fn func(CandleRow) error) error {
+	if _, ok := CandleTables[resolution]; !ok {
+		return fmt.Errorf("unknown candle resolution %q", resolution)
+	}
+
+	s.mu.RLock()
+	var rows []CandleRow
+	for key, candles := range s.candles {
+		if key.resolution != resolution {
+			continue
+		}
+		for _, c := range candles {
+			if filter.matches(key.mode, key.itemID, c.Timestamp) {
+				rows = append(rows, CandleRow{ItemID: key.itemID, GameMode: key.mode, Timestamp: c.Timestamp,
+					BuyOpen: c.BuyOpen, BuyHigh: c.BuyHigh, BuyLow: c.BuyLow, BuyClose: c.BuyClose,
+					SellOpen: c.SellOpen, SellHigh: c.SellHigh, SellLow: c.SellLow, SellClose: c.SellClose,
+					Ticks: c.Ticks})
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].ItemID != rows[j].ItemID {
+			return rows[i].ItemID < rows[j].ItemID
+		}
+		return rows[i].Timestamp.Before(rows[j].Timestamp)
+	})
+	for _, r := range rows {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
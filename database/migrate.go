@@ -0,0 +1,229 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the schema migrations of each dialect under
+// migrations/<dialect>, named <version>_<name>.sql. Versions are applied in
+// order and a migration is never edited once released; schema changes go in a
+// new file with the next version, written for every dialect.
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Checksum string // SHA-256 of SQL, to detect edits to applied migrations
+}
+
+// MigrationStatus reports whether a migration has been applied to the database
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations returns the embedded migrations of the open database's
+// dialect ordered by version. Versions must start at 1 and have no gaps or
+// duplicates.
+func LoadMigrations() ([]Migration, error) {
+	dir := path.Join("migrations", Dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		file := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(file, ".sql") {
+			continue
+		}
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.sql", file)
+		}
+		body, err := migrationFiles.ReadFile(path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(body)
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     name,
+			SQL:      string(body),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must run 1..n without gaps, found %d at position %d", m.Version, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// Migrate brings the schema up to date, applying each pending migration in its
+// own transaction and recording it in schema_migrations. It stops at the first
+// failure, leaving the database at the last version that applied cleanly. It
+// returns the migrations that were applied.
+func Migrate(ctx context.Context) ([]Migration, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApplied(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	if len(applied) == 0 && Dialect == DialectSQLite {
+		if err := upgradeLegacySchema(ctx); err != nil {
+			return nil, fmt.Errorf("upgrading pre-migration schema: %v", err)
+		}
+	}
+
+	var ran []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := applyMigration(ctx, m); err != nil {
+			return ran, fmt.Errorf("migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+		log.Printf("Applied migration %d (%s)", m.Version, m.Name)
+		ran = append(ran, m)
+	}
+	return ran, nil
+}
+
+// MigrationStatuses lists every embedded migration with when it was applied,
+// or a nil AppliedAt if it is pending
+func MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApplied(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i].Migration = m
+		if record, ok := applied[m.Version]; ok {
+			appliedAt := record.appliedAt
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// createMigrationsTable creates schema_migrations in SQL both dialects accept,
+// since it must exist before any migration can run
+func createMigrationsTable(ctx context.Context) error {
+	_, err := DB.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+func appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	rows, err := DB.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var record appliedMigration
+		if err := rows.Scan(&version, &record.checksum, &record.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = record
+	}
+	return applied, rows.Err()
+}
+
+// checkApplied refuses to run against a database migrated by a newer build, or
+// one whose applied migrations have since been edited
+func checkApplied(migrations []Migration, applied map[int]appliedMigration) error {
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	for version, record := range applied {
+		m, ok := known[version]
+		if !ok {
+			return fmt.Errorf("database has migration %d applied, which this build does not know; it was migrated by a newer version", version)
+		}
+		if m.Checksum != record.checksum {
+			return fmt.Errorf("migration %d (%s) has been edited since it was applied; add a new migration instead", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, m Migration) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)
+	`, m.Version, m.Name, m.Checksum); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
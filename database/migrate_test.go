@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openEmptyDB opens a new SQLite file for one test without migrating it
+func openEmptyDB(t *testing.T) {
+	t.Helper()
+	Open(Options{DSN: filepath.Join(t.TempDir(), "test.db"), BusyTimeout: 5 * time.Second, WAL: true, MaxReaders: 4})
+	t.Cleanup(Close)
+}
+
+func TestMigrateFromEmpty(t *testing.T) {
+	ctx := context.Background()
+	openEmptyDB(t)
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran, err := Migrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != len(migrations) {
+		t.Errorf("applied %d migrations, want all %d", len(ran), len(migrations))
+	}
+	statuses, err := MigrationStatuses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %d (%s) is pending", s.Version, s.Name)
+		}
+	}
+	for _, table := range []string{"item_prices", "item_analytics", "item_catalog", "job_runs", "raw_snapshots"} {
+		if exists, err := tableExists(ctx, table); err != nil || !exists {
+			t.Errorf("table %s missing (%v)", table, err)
+		}
+	}
+
+	// A second run has nothing to do
+	if ran, err := Migrate(ctx); err != nil || len(ran) != 0 {
+		t.Errorf("second run applied %d migrations (%v), want none", len(ran), err)
+	}
+}
+
+func TestMigrateRefusals(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  string
+		wantErr string
+	}{
+		{"edited migration", `UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`, "has been edited since it was applied"},
+		{"unknown version", `INSERT INTO schema_migrations (version, name, checksum) VALUES (999, 'future', 'x')`, "migrated by a newer version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			openEmptyDB(t)
+			if _, err := Migrate(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := DB.Exec(tt.tamper); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Migrate(ctx); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Migrate: got %v, want %q", err, tt.wantErr)
+			}
+			if _, err := MigrationStatuses(ctx); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("MigrationStatuses: got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// legacySchema is a database as created before schema migrations and game
+// modes: no game_mode anywhere, item_analytics keyed by item alone and the
+// averages by item and bucket, and none of the later item_prices columns
+const legacySchema = `
+CREATE TABLE item_prices (
+    id INTEGER PRIMARY KEY,
+    item_id INTEGER,
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    buy_price INTEGER,
+    sell_price INTEGER
+);
+CREATE TABLE item_analytics (
+    item_id INTEGER PRIMARY KEY,
+    sma5_buy REAL,
+    sma5_sell REAL,
+    last_updated DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE item_averages_5m (
+    item_id INTEGER NOT NULL,
+    timestamp DATETIME NOT NULL,
+    avg_high_price INTEGER,
+    avg_low_price INTEGER,
+    high_price_volume INTEGER DEFAULT 0,
+    low_price_volume INTEGER DEFAULT 0,
+    PRIMARY KEY (item_id, timestamp)
+);
+CREATE TABLE item_averages_1h (
+    item_id INTEGER NOT NULL,
+    timestamp DATETIME NOT NULL,
+    avg_high_price INTEGER,
+    avg_low_price INTEGER,
+    high_price_volume INTEGER DEFAULT 0,
+    low_price_volume INTEGER DEFAULT 0,
+    PRIMARY KEY (item_id, timestamp)
+);
+CREATE TABLE price_quarantine (
+    id INTEGER PRIMARY KEY,
+    item_id INTEGER NOT NULL,
+    buy_price INTEGER,
+    sell_price INTEGER,
+    buy_time DATETIME,
+    sell_time DATETIME,
+    reason TEXT NOT NULL,
+    quarantined_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE raw_snapshots (
+    id INTEGER PRIMARY KEY,
+    endpoint TEXT NOT NULL,
+    fetched_at DATETIME NOT NULL,
+    payload BLOB NOT NULL
+);
+
+INSERT INTO item_prices (item_id, timestamp, buy_price, sell_price) VALUES
+    (2, '2024-01-01 10:00:00', 150, 160),
+    (2, '2024-01-01 10:10:00', 151, 161),
+    (560, '2024-01-01 10:00:00', 200, 210);
+INSERT INTO item_analytics (item_id, sma5_buy, sma5_sell) VALUES (2, 150.5, 160.5), (560, 200, 210);
+INSERT INTO item_averages_5m (item_id, timestamp, avg_high_price, avg_low_price, high_price_volume, low_price_volume) VALUES
+    (2, '2024-01-01 10:00:00', 160, 150, 1000, 900),
+    (2, '2024-01-01 10:05:00', 161, 151, 1100, 950);
+INSERT INTO item_averages_1h (item_id, timestamp, avg_high_price, avg_low_price) VALUES (560, '2024-01-01 10:00:00', 210, 200);
+INSERT INTO price_quarantine (item_id, buy_price, sell_price, reason) VALUES (2, 9000, 9100, 'spike: buy 9000 vs median 150');
+INSERT INTO raw_snapshots (endpoint, fetched_at, payload) VALUES ('latest', '2024-01-01 10:00:00', x'1f8b');
+`
+
+func TestMigrateLegacySQLite(t *testing.T) {
+	ctx := context.Background()
+	openEmptyDB(t)
+	if _, err := DB.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran, err := Migrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != len(migrations) {
+		t.Errorf("applied %d migrations, want all %d", len(ran), len(migrations))
+	}
+
+	// Every row survives as main game data
+	for table, want := range map[string]int{
+		"item_prices":      3,
+		"item_analytics":   2,
+		"item_averages_5m": 2,
+		"item_averages_1h": 1,
+		"price_quarantine": 1,
+		"raw_snapshots":    1,
+	} {
+		var rows, osrs int
+		err := DB.QueryRow(`SELECT COUNT(*), COUNT(CASE WHEN game_mode = 'osrs' THEN 1 END) FROM `+table).Scan(&rows, &osrs)
+		if err != nil {
+			t.Fatalf("%s: %v", table, err)
+		}
+		if rows != want || osrs != want {
+			t.Errorf("%s has %d rows, %d of them osrs, want %d", table, rows, osrs, want)
+		}
+	}
+
+	var buy, sell float64
+	if err := DB.QueryRow(`SELECT sma5_buy, sma5_sell FROM item_analytics WHERE item_id = 2`).Scan(&buy, &sell); err != nil {
+		t.Fatal(err)
+	}
+	if buy != 150.5 || sell != 160.5 {
+		t.Errorf("item 2 analytics %v/%v, want 150.5/160.5", buy, sell)
+	}
+	var volume int
+	if err := DB.QueryRow(`SELECT high_price_volume FROM item_averages_5m WHERE item_id = 2 AND timestamp = '2024-01-01 10:05:00'`).Scan(&volume); err != nil {
+		t.Fatal(err)
+	}
+	if volume != 1100 {
+		t.Errorf("5m volume %d, want 1100", volume)
+	}
+	var quality string
+	if err := DB.QueryRow(`SELECT quality FROM item_prices WHERE item_id = 560`).Scan(&quality); err != nil {
+		t.Fatal(err)
+	}
+	if quality != "unchecked" {
+		t.Errorf("legacy quote quality %q, want unchecked", quality)
+	}
+
+	// The rebuilt tables are keyed by game mode as well
+	if _, err := DB.Exec(`INSERT INTO item_analytics (item_id, game_mode, sma5_buy, sma5_sell) VALUES (2, 'dmm', 1, 1)`); err != nil {
+		t.Errorf("item_analytics still keyed by item alone: %v", err)
+	}
+	if _, err := DB.Exec(`INSERT INTO item_averages_5m (item_id, game_mode, timestamp) VALUES (2, 'dmm', '2024-01-01 10:00:00')`); err != nil {
+		t.Errorf("item_averages_5m still keyed by item and bucket: %v", err)
+	}
+	if _, err := DB.Exec(`INSERT INTO item_averages_24h (item_id, game_mode, timestamp) VALUES (2, 'osrs', '2024-01-01 00:00:00')`); err != nil {
+		t.Errorf("item_averages_24h missing from the legacy database was not created: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+-- Baseline schema: every table as of the introduction of versioned migrations,
+-- in PostgreSQL types. Timestamps are stored as UTC without a time zone, the
+-- same wall-clock values the SQLite schema holds.
+
+CREATE TABLE IF NOT EXISTS item_prices (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    item_id BIGINT,
+    timestamp TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'),
+    buy_price BIGINT,
+    sell_price BIGINT,
+    buy_time TIMESTAMP,
+    sell_time TIMESTAMP,
+    quality TEXT DEFAULT 'unchecked',
+    game_mode TEXT NOT NULL DEFAULT 'osrs'
+);
+
+CREATE TABLE IF NOT EXISTS item_analytics (
+    item_id BIGINT NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    sma5_buy DOUBLE PRECISION,
+    sma5_sell DOUBLE PRECISION,
+    rsi_14 DOUBLE PRECISION DEFAULT 0,
+    macd_line DOUBLE PRECISION DEFAULT 0,
+    macd_signal DOUBLE PRECISION DEFAULT 0,
+    macd_hist DOUBLE PRECISION DEFAULT 0,
+    last_updated TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'),
+    PRIMARY KEY (item_id, game_mode)
+);
+
+CREATE TABLE IF NOT EXISTS item_averages_5m (
+    item_id BIGINT NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp TIMESTAMP NOT NULL,
+    avg_high_price BIGINT,
+    avg_low_price BIGINT,
+    high_price_volume BIGINT DEFAULT 0,
+    low_price_volume BIGINT DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS item_averages_1h (
+    item_id BIGINT NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp TIMESTAMP NOT NULL,
+    avg_high_price BIGINT,
+    avg_low_price BIGINT,
+    high_price_volume BIGINT DEFAULT 0,
+    low_price_volume BIGINT DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS item_averages_24h (
+    item_id BIGINT NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp TIMESTAMP NOT NULL,
+    avg_high_price BIGINT,
+    avg_low_price BIGINT,
+    high_price_volume BIGINT DEFAULT 0,
+    low_price_volume BIGINT DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS guide_prices (
+    item_id BIGINT NOT NULL,
+    date TIMESTAMP NOT NULL,
+    price BIGINT NOT NULL,
+    fetched_at TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'),
+    PRIMARY KEY (item_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS price_quarantine (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    item_id BIGINT NOT NULL,
+    buy_price BIGINT,
+    sell_price BIGINT,
+    buy_time TIMESTAMP,
+    sell_time TIMESTAMP,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    reason TEXT NOT NULL,
+    quarantined_at TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+);
+
+CREATE TABLE IF NOT EXISTS fetch_failures (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    url TEXT NOT NULL,
+    status_code BIGINT,
+    attempts BIGINT,
+    error TEXT,
+    failed_at TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+);
+
+CREATE TABLE IF NOT EXISTS item_catalog (
+    id BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    examine TEXT DEFAULT '',
+    members BOOLEAN DEFAULT FALSE,
+    buy_limit BIGINT DEFAULT 0,
+    highalch BIGINT DEFAULT 0,
+    lowalch BIGINT DEFAULT 0,
+    value BIGINT DEFAULT 0,
+    icon TEXT DEFAULT '',
+    updated_at TIMESTAMP DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')
+);
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    job TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL,
+    duration_ms BIGINT,
+    error TEXT
+);
+
+CREATE TABLE IF NOT EXISTS raw_snapshots (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    endpoint TEXT NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    fetched_at TIMESTAMP NOT NULL,
+    payload BYTEA NOT NULL
+);
@@ -0,0 +1,15 @@
+-- Price history lookups: every analytics and history query filters one item's
+-- market and orders or limits by time.
+CREATE INDEX IF NOT EXISTS idx_item_prices_item_time
+    ON item_prices (item_id, game_mode, timestamp);
+
+-- Duplicate-quote check run for every item on every snapshot.
+CREATE INDEX IF NOT EXISTS idx_item_prices_item_trades
+    ON item_prices (item_id, game_mode, buy_time, sell_time);
+
+-- The same duplicate check against quarantined quotes.
+CREATE INDEX IF NOT EXISTS idx_price_quarantine_item_trades
+    ON price_quarantine (item_id, game_mode, buy_time, sell_time);
+
+-- Let the planner see the new indexes' selectivity straight away.
+ANALYZE;
@@ -0,0 +1,33 @@
+-- OHLC candles that raw item_prices ticks are rolled into once they pass the
+-- retention age. Prices of 0 mean that side did not trade in the bucket.
+CREATE TABLE IF NOT EXISTS item_candles_1h (
+    item_id BIGINT NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp TIMESTAMP NOT NULL,
+    buy_open BIGINT NOT NULL DEFAULT 0,
+    buy_high BIGINT NOT NULL DEFAULT 0,
+    buy_low BIGINT NOT NULL DEFAULT 0,
+    buy_close BIGINT NOT NULL DEFAULT 0,
+    sell_open BIGINT NOT NULL DEFAULT 0,
+    sell_high BIGINT NOT NULL DEFAULT 0,
+    sell_low BIGINT NOT NULL DEFAULT 0,
+    sell_close BIGINT NOT NULL DEFAULT 0,
+    ticks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS item_candles_1d (
+    item_id BIGINT NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp TIMESTAMP NOT NULL,
+    buy_open BIGINT NOT NULL DEFAULT 0,
+    buy_high BIGINT NOT NULL DEFAULT 0,
+    buy_low BIGINT NOT NULL DEFAULT 0,
+    buy_close BIGINT NOT NULL DEFAULT 0,
+    sell_open BIGINT NOT NULL DEFAULT 0,
+    sell_high BIGINT NOT NULL DEFAULT 0,
+    sell_low BIGINT NOT NULL DEFAULT 0,
+    sell_close BIGINT NOT NULL DEFAULT 0,
+    ticks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
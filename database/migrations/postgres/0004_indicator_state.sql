@@ -0,0 +1,34 @@
+-- RSI and MACD of the sell price, the mid price and the spread, next to the
+-- existing buy price columns.
+ALTER TABLE item_analytics ADD COLUMN rsi_14_sell DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_line_sell DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_signal_sell DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_hist_sell DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN rsi_14_mid DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_line_mid DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_signal_mid DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_hist_mid DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN rsi_14_spread DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_line_spread DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_signal_spread DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_hist_spread DOUBLE PRECISION DEFAULT 0;
+
+-- Running RSI and MACD state per item and series, so each new item_prices tick
+-- updates the indicators without re-reading the item's history. last_id and
+-- last_timestamp mark the last tick folded in; items without a row for every
+-- series have their whole history folded in on the next analytics update.
+CREATE TABLE IF NOT EXISTS item_indicator_state (
+    item_id BIGINT NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    series TEXT NOT NULL,
+    last_id BIGINT NOT NULL,
+    last_timestamp TIMESTAMP NOT NULL,
+    ticks BIGINT NOT NULL DEFAULT 0,
+    last_value DOUBLE PRECISION NOT NULL DEFAULT 0,
+    avg_gain DOUBLE PRECISION NOT NULL DEFAULT 0,
+    avg_loss DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ema_fast DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ema_slow DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ema_signal DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, series)
+);
@@ -0,0 +1,120 @@
+-- Baseline schema: every table as of the introduction of versioned migrations.
+-- Databases created before then are brought up to this shape by
+-- upgradeLegacySchema before it runs, so every statement is idempotent.
+
+CREATE TABLE IF NOT EXISTS item_prices (
+    id INTEGER PRIMARY KEY,
+    item_id INTEGER,
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    buy_price INTEGER,
+    sell_price INTEGER,
+    buy_time DATETIME,
+    sell_time DATETIME,
+    quality TEXT DEFAULT 'unchecked',
+    game_mode TEXT NOT NULL DEFAULT 'osrs'
+);
+
+CREATE TABLE IF NOT EXISTS item_analytics (
+    item_id INTEGER NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    sma5_buy REAL,
+    sma5_sell REAL,
+    rsi_14 REAL DEFAULT 0,
+    macd_line REAL DEFAULT 0,
+    macd_signal REAL DEFAULT 0,
+    macd_hist REAL DEFAULT 0,
+    last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (item_id, game_mode)
+);
+
+CREATE TABLE IF NOT EXISTS item_averages_5m (
+    item_id INTEGER NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp DATETIME NOT NULL,
+    avg_high_price INTEGER,
+    avg_low_price INTEGER,
+    high_price_volume INTEGER DEFAULT 0,
+    low_price_volume INTEGER DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS item_averages_1h (
+    item_id INTEGER NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp DATETIME NOT NULL,
+    avg_high_price INTEGER,
+    avg_low_price INTEGER,
+    high_price_volume INTEGER DEFAULT 0,
+    low_price_volume INTEGER DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS item_averages_24h (
+    item_id INTEGER NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp DATETIME NOT NULL,
+    avg_high_price INTEGER,
+    avg_low_price INTEGER,
+    high_price_volume INTEGER DEFAULT 0,
+    low_price_volume INTEGER DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS guide_prices (
+    item_id INTEGER NOT NULL,
+    date DATETIME NOT NULL,
+    price INTEGER NOT NULL,
+    fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (item_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS price_quarantine (
+    id INTEGER PRIMARY KEY,
+    item_id INTEGER NOT NULL,
+    buy_price INTEGER,
+    sell_price INTEGER,
+    buy_time DATETIME,
+    sell_time DATETIME,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    reason TEXT NOT NULL,
+    quarantined_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS fetch_failures (
+    id INTEGER PRIMARY KEY,
+    url TEXT NOT NULL,
+    status_code INTEGER,
+    attempts INTEGER,
+    error TEXT,
+    failed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS item_catalog (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    examine TEXT DEFAULT '',
+    members BOOLEAN DEFAULT 0,
+    buy_limit INTEGER DEFAULT 0,
+    highalch INTEGER DEFAULT 0,
+    lowalch INTEGER DEFAULT 0,
+    value INTEGER DEFAULT 0,
+    icon TEXT DEFAULT '',
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id INTEGER PRIMARY KEY,
+    job TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NOT NULL,
+    duration_ms INTEGER,
+    error TEXT
+);
+
+CREATE TABLE IF NOT EXISTS raw_snapshots (
+    id INTEGER PRIMARY KEY,
+    endpoint TEXT NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    fetched_at DATETIME NOT NULL,
+    payload BLOB NOT NULL
+);
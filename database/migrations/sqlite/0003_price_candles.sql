@@ -0,0 +1,33 @@
+-- OHLC candles that raw item_prices ticks are rolled into once they pass the
+-- retention age. Prices of 0 mean that side did not trade in the bucket.
+CREATE TABLE IF NOT EXISTS item_candles_1h (
+    item_id INTEGER NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp DATETIME NOT NULL,
+    buy_open INTEGER NOT NULL DEFAULT 0,
+    buy_high INTEGER NOT NULL DEFAULT 0,
+    buy_low INTEGER NOT NULL DEFAULT 0,
+    buy_close INTEGER NOT NULL DEFAULT 0,
+    sell_open INTEGER NOT NULL DEFAULT 0,
+    sell_high INTEGER NOT NULL DEFAULT 0,
+    sell_low INTEGER NOT NULL DEFAULT 0,
+    sell_close INTEGER NOT NULL DEFAULT 0,
+    ticks INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
+
+CREATE TABLE IF NOT EXISTS item_candles_1d (
+    item_id INTEGER NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    timestamp DATETIME NOT NULL,
+    buy_open INTEGER NOT NULL DEFAULT 0,
+    buy_high INTEGER NOT NULL DEFAULT 0,
+    buy_low INTEGER NOT NULL DEFAULT 0,
+    buy_close INTEGER NOT NULL DEFAULT 0,
+    sell_open INTEGER NOT NULL DEFAULT 0,
+    sell_high INTEGER NOT NULL DEFAULT 0,
+    sell_low INTEGER NOT NULL DEFAULT 0,
+    sell_close INTEGER NOT NULL DEFAULT 0,
+    ticks INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, timestamp)
+);
@@ -0,0 +1,34 @@
+-- RSI and MACD of the sell price, the mid price and the spread, next to the
+-- existing buy price columns.
+ALTER TABLE item_analytics ADD COLUMN rsi_14_sell REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_line_sell REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_signal_sell REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_hist_sell REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN rsi_14_mid REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_line_mid REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_signal_mid REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_hist_mid REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN rsi_14_spread REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_line_spread REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_signal_spread REAL DEFAULT 0;
+ALTER TABLE item_analytics ADD COLUMN macd_hist_spread REAL DEFAULT 0;
+
+-- Running RSI and MACD state per item and series, so each new item_prices tick
+-- updates the indicators without re-reading the item's history. last_id and
+-- last_timestamp mark the last tick folded in; items without a row for every
+-- series have their whole history folded in on the next analytics update.
+CREATE TABLE IF NOT EXISTS item_indicator_state (
+    item_id INTEGER NOT NULL,
+    game_mode TEXT NOT NULL DEFAULT 'osrs',
+    series TEXT NOT NULL,
+    last_id INTEGER NOT NULL,
+    last_timestamp DATETIME NOT NULL,
+    ticks INTEGER NOT NULL DEFAULT 0,
+    last_value REAL NOT NULL DEFAULT 0,
+    avg_gain REAL NOT NULL DEFAULT 0,
+    avg_loss REAL NOT NULL DEFAULT 0,
+    ema_fast REAL NOT NULL DEFAULT 0,
+    ema_slow REAL NOT NULL DEFAULT 0,
+    ema_signal REAL NOT NULL DEFAULT 0,
+    PRIMARY KEY (item_id, game_mode, series)
+);
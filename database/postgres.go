@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// isPostgresDSN reports whether the DSN is a PostgreSQL connection URL rather
+// than a SQLite file
+func isPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// openPostgres opens a pool on a PostgreSQL server. Sessions run in UTC so
+// TIMESTAMP columns hold the same UTC wall-clock text SQLite stores, and a
+// read-only pool refuses writes the way SQLite's mode=ro does.
+func openPostgres(dsn string, readOnly bool) (*sql.DB, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.RuntimeParams["timezone"] = "UTC"
+	if readOnly {
+		config.RuntimeParams["default_transaction_read_only"] = "on"
+	}
+	return sql.OpenDB(postgresConnector{stdlib.GetConnector(*config)}), nil
+}
+
+// postgresConnector hands out connections that accept the ? placeholders the
+// queries are written with
+type postgresConnector struct {
+	driver.Connector
+}
+
+func (c postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return postgresConn{conn.(*stdlib.Conn)}, nil
+}
+
+// postgresConn rewrites ? placeholders to PostgreSQL's $1, $2, ... before
+// passing statements to pgx
+type postgresConn struct {
+	*stdlib.Conn
+}
+
+func (c postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebind(query))
+}
+
+func (c postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.PrepareContext(ctx, rebind(query))
+}
+
+func (c postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.ExecContext(ctx, rebind(query), args)
+}
+
+func (c postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.QueryContext(ctx, rebind(query), args)
+}
+
+// rebind numbers the ? placeholders in a query, leaving question marks inside
+// string literals, quoted identifiers and comments alone
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	var quote byte // Closing quote of the literal being copied, or 0
+	comment, blockComment := false, false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case comment:
+			comment = ch != '\n'
+		case blockComment:
+			if ch == '*' && i+1 < len(query) && query[i+1] == '/' {
+				blockComment = false
+				b.WriteString("*/")
+				i++
+				continue
+			}
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '-' && i+1 < len(query) && query[i+1] == '-':
+			comment = true
+		case ch == '/' && i+1 < len(query) && query[i+1] == '*':
+			blockComment = true
+			b.WriteString("/*")
+			i++
+			continue
+		case ch == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
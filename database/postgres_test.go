@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRebind(t *testing.T) {
+	for _, tc := range []struct {
+		name, query, want string
+	}{
+		{"no placeholders", `SELECT 1`, `SELECT 1`},
+		{"placeholders", `SELECT * FROM t WHERE a = ? AND b IN (?, ?)`, `SELECT * FROM t WHERE a = $1 AND b IN ($2, $3)`},
+		{"string literal", `SELECT '?' , ? WHERE name = 'what?'`, `SELECT '?' , $1 WHERE name = 'what?'`},
+		{"escaped quote", `SELECT 'it''s ?' WHERE a = ?`, `SELECT 'it''s ?' WHERE a = $1`},
+		{"quoted identifier", `SELECT "odd?column" FROM t WHERE a = ?`, `SELECT "odd?column" FROM t WHERE a = $1`},
+		{"line comment", "SELECT ? -- why?\nWHERE a = ?", "SELECT $1 -- why?\nWHERE a = $2"},
+		{"block comment", `SELECT ? /* why? 'unclosed */ WHERE a = ?`, `SELECT $1 /* why? 'unclosed */ WHERE a = $2`},
+		{"block comment opener then slash", `SELECT ? /*/ ? */ , ?`, `SELECT $1 /*/ ? */ , $2`},
+	} {
+		if got := rebind(tc.query); got != tc.want {
+			t.Errorf("%s: rebind(%q) = %q, want %q", tc.name, tc.query, got, tc.want)
+		}
+	}
+}
+
+// TestSQLStorePostgres runs the migrations and the SQLStore checks against the
+// PostgreSQL server in FLIP_TEST_POSTGRES_DSN, inside a schema of its own that
+// is dropped afterwards
+func TestSQLStorePostgres(t *testing.T) {
+	dsn := os.Getenv("FLIP_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("FLIP_TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := openPostgres(dsn, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("flip_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		admin.Close()
+		t.Fatalf("creating schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("dropping schema: %v", err)
+		}
+		admin.Close()
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	openTestDB(t, u.String())
+	if Dialect != DialectPostgres {
+		t.Fatalf("dialect = %q, want %q", Dialect, DialectPostgres)
+	}
+	testSQLStore(t)
+}
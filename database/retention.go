@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// PruneOperationalData deletes job run history, fetch failures and
+// quarantined quotes older than logAge, and archived snapshots older than
+// snapshotAge. A zero age keeps that data forever. It returns the number of
+// rows deleted.
+func PruneOperationalData(ctx context.Context, logAge, snapshotAge time.Duration) (int64, error) {
+	var deleted int64
+
+	prune := func(query string, age time.Duration) error {
+		if age <= 0 {
+			return nil
+		}
+		cutoff := time.Now().Add(-age).UTC().Format(TimestampLayout)
+		res, err := DB.ExecContext(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+		return nil
+	}
+
+	if err := prune(`DELETE FROM job_runs WHERE started_at < ?`, logAge); err != nil {
+		return deleted, err
+	}
+	if err := prune(`DELETE FROM fetch_failures WHERE failed_at < ?`, logAge); err != nil {
+		return deleted, err
+	}
+	if err := prune(`DELETE FROM price_quarantine WHERE quarantined_at < ?`, logAge); err != nil {
+		return deleted, err
+	}
+	if err := prune(`DELETE FROM raw_snapshots WHERE fetched_at < ?`, snapshotAge); err != nil {
+		return deleted, err
+	}
+	return deleted, nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"io"
+	"time"
+)
+
+// Snapshot is a raw API response kept for reprocessing
+type Snapshot struct {
+	ID        int64
+	Endpoint  string
+	GameMode  string
+	FetchedAt time.Time
+	Payload   []byte // Decompressed response body
+}
+
+// StoreSnapshot archives a raw response body, gzip-compressed, with the game
+// mode it belongs to and the time it was fetched
+func StoreSnapshot(ctx context.Context, mode, endpoint string, fetchedAt time.Time, body []byte) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	_, err := DB.ExecContext(ctx, `
+		INSERT INTO raw_snapshots (endpoint, game_mode, fetched_at, payload)
+		VALUES (?, ?, ?, ?)
+	`, endpoint, mode, fetchedAt.UTC().Format(TimestampLayout), buf.Bytes())
+	return err
+}
+
+// NextSnapshot returns the first snapshot for endpoint and game mode with an
+// ID above afterID that was fetched in [since, until), or nil when there are
+// none left. Zero times leave that end of the range open.
+func NextSnapshot(ctx context.Context, mode, endpoint string, afterID int64, since, until time.Time) (*Snapshot, error) {
+	query := `SELECT id, fetched_at, payload FROM raw_snapshots WHERE endpoint = ? AND game_mode = ? AND id > ?`
+	args := []interface{}{endpoint, mode, afterID}
+	if !since.IsZero() {
+		query += ` AND fetched_at >= ?`
+		args = append(args, since.UTC().Format(TimestampLayout))
+	}
+	if !until.IsZero() {
+		query += ` AND fetched_at < ?`
+		args = append(args, until.UTC().Format(TimestampLayout))
+	}
+	query += ` ORDER BY id ASC LIMIT 1`
+
+	var snapshot Snapshot
+	var compressed []byte
+	err := ReadDB.QueryRowContext(ctx, query, args...).Scan(&snapshot.ID, &snapshot.FetchedAt, &compressed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	snapshot.Payload, err = io.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Endpoint = endpoint
+	snapshot.GameMode = mode
+	return &snapshot, nil
+}
@@ -0,0 +1,319 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLStore serves the Store queries from a SQLite or PostgreSQL pool. The item
+// catalog comes from the in-memory copy of item_catalog.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore creates a store reading through db, normally ReadDB
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) PriceHistory(ctx context.Context, mode string, itemID int) ([]PricePoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT timestamp, buy_price, sell_price
+		FROM item_prices
+		WHERE item_id = ? AND game_mode = ?
+		ORDER BY timestamp ASC
+	`, itemID, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []PricePoint
+	for rows.Next() {
+		var p PricePoint
+		if err := rows.Scan(&p.Timestamp, &p.BuyPrice, &p.SellPrice); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (s *SQLStore) Candles(ctx context.Context, mode, resolution string, itemID int) ([]Candle, error) {
+	table, ok := CandleTables[resolution]
+	if !ok {
+		return nil, fmt.Errorf("unknown candle resolution %q", resolution)
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT timestamp, buy_open, buy_high, buy_low, buy_close,
+			sell_open, sell_high, sell_low, sell_close, ticks
+		FROM %s
+		WHERE item_id = ? AND game_mode = ?
+		ORDER BY timestamp ASC
+	`, table), itemID, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candles []Candle
+	for rows.Next() {
+		var c Candle
+		if err := rows.Scan(&c.Timestamp, &c.BuyOpen, &c.BuyHigh, &c.BuyLow, &c.BuyClose,
+			&c.SellOpen, &c.SellHigh, &c.SellLow, &c.SellClose, &c.Ticks); err != nil {
+			return nil, err
+		}
+		candles = append(candles, c)
+	}
+	return candles, rows.Err()
+}
+
+func (s *SQLStore) Averages(ctx context.Context, mode, resolution string, itemID int) ([]Average, error) {
+	table, ok := AverageTables[resolution]
+	if !ok {
+		return nil, fmt.Errorf("unknown average resolution %q", resolution)
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT timestamp, COALESCE(avg_high_price, 0), COALESCE(avg_low_price, 0), high_price_volume, low_price_volume
+		FROM %s
+		WHERE item_id = ? AND game_mode = ?
+		ORDER BY timestamp ASC
+	`, table), itemID, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var averages []Average
+	for rows.Next() {
+		var a Average
+		if err := rows.Scan(&a.Timestamp, &a.AvgHighPrice, &a.AvgLowPrice, &a.HighPriceVolume, &a.LowPriceVolume); err != nil {
+			return nil, err
+		}
+		averages = append(averages, a)
+	}
+	return averages, rows.Err()
+}
+
+func (s *SQLStore) RecentVolumes(ctx context.Context, mode string, since, until time.Time) (map[int]Volume, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT item_id, SUM(high_price_volume), SUM(low_price_volume)
+		FROM item_averages_5m
+		WHERE game_mode = ? AND timestamp >= ? AND timestamp < ?
+		GROUP BY item_id
+	`, mode, since.UTC().Format(TimestampLayout), until.UTC().Format(TimestampLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	volumes := make(map[int]Volume)
+	for rows.Next() {
+		var itemID int
+		var v Volume
+		if err := rows.Scan(&itemID, &v.High, &v.Low); err != nil {
+			return nil, err
+		}
+		volumes[itemID] = v
+	}
+	return volumes, rows.Err()
+}
+
+func (s *SQLStore) LatestGuidePrices(ctx context.Context) (map[int]GuidePrice, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT g.item_id, g.date, g.price
+		FROM guide_prices g
+		WHERE g.date = (SELECT MAX(date) FROM guide_prices WHERE item_id = g.item_id)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make(map[int]GuidePrice)
+	for rows.Next() {
+		var itemID int
+		var p GuidePrice
+		if err := rows.Scan(&itemID, &p.Date, &p.Price); err != nil {
+			return nil, err
+		}
+		prices[itemID] = p
+	}
+	return prices, rows.Err()
+}
+
+func (s *SQLStore) QuarantinedQuotes(ctx context.Context, mode string, itemID, limit int) ([]QuarantinedQuote, error) {
+	query := `
+		SELECT item_id, COALESCE(buy_price, 0), COALESCE(sell_price, 0), buy_time, sell_time, reason, quarantined_at
+		FROM price_quarantine
+		WHERE game_mode = ?
+	`
+	args := []interface{}{mode}
+	if itemID != 0 {
+		query += ` AND item_id = ?`
+		args = append(args, itemID)
+	}
+	query += ` ORDER BY quarantined_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotes := make([]QuarantinedQuote, 0)
+	for rows.Next() {
+		var q QuarantinedQuote
+		var buyTime, sellTime sql.NullString
+		if err := rows.Scan(&q.ItemID, &q.BuyPrice, &q.SellPrice, &buyTime, &sellTime, &q.Reason, &q.QuarantinedAt); err != nil {
+			return nil, err
+		}
+		q.BuyTime, q.SellTime = buyTime.String, sellTime.String
+		quotes = append(quotes, q)
+	}
+	return quotes, rows.Err()
+}
+
+func (s *SQLStore) Analytics(ctx context.Context, mode string) ([]ItemAnalytics, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT item_id, game_mode, COALESCE(sma5_buy, 0), COALESCE(sma5_sell, 0),
+			`+indicatorSelectList+`
+		FROM item_analytics
+		WHERE game_mode = ?
+	`, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var analytics []ItemAnalytics
+	for rows.Next() {
+		var a ItemAnalytics
+		if err := rows.Scan(append([]interface{}{&a.ItemID, &a.GameMode, &a.SMA5Buy, &a.SMA5Sell},
+			a.indicatorFields()...)...); err != nil {
+			return nil, err
+		}
+		analytics = append(analytics, a)
+	}
+	return analytics, rows.Err()
+}
+
+// AnalyticsAsOf streams the quotes of one game mode item by item, so only one
+// item's history is held in memory at a time
+func (s *SQLStore) AnalyticsAsOf(ctx context.Context, mode string, asOf time.Time) ([]ItemAnalytics, bool, error) {
+	var oldest time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT timestamp FROM item_prices WHERE game_mode = ? ORDER BY timestamp LIMIT 1
+	`, mode).Scan(&oldest)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if asOf.Before(oldest) {
+		return nil, false, fmt.Errorf("%w, the oldest is from %s", ErrBeforeHistory, oldest.UTC().Format(time.RFC3339))
+	}
+
+	// Daily candles only exist for days retention has compacted
+	var compacted int
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM (SELECT 1 FROM item_candles_1d WHERE game_mode = ? LIMIT 1) AS c`, mode).Scan(&compacted)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT item_id, buy_price, sell_price
+		FROM item_prices
+		WHERE game_mode = ? AND timestamp <= ?
+		ORDER BY item_id, timestamp, id
+	`, mode, asOf.UTC().Format(TimestampLayout))
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var analytics []ItemAnalytics
+	var buys, sells []float64
+	current := 0
+	for rows.Next() {
+		var itemID, buy, sell int
+		if err := rows.Scan(&itemID, &buy, &sell); err != nil {
+			return nil, false, err
+		}
+		if itemID != current && len(buys) > 0 {
+			analytics = append(analytics, AnalyticsFromPrices(mode, current, buys, sells))
+			buys, sells = buys[:0], sells[:0]
+		}
+		current = itemID
+		buys = append(buys, float64(buy))
+		sells = append(sells, float64(sell))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(buys) > 0 {
+		analytics = append(analytics, AnalyticsFromPrices(mode, current, buys, sells))
+	}
+	return analytics, compacted > 0, nil
+}
+
+func (s *SQLStore) Item(itemID int) (Item, bool) { return Catalog.Item(itemID) }
+
+func (s *SQLStore) ItemName(itemID int) string { return Catalog.Name(itemID) }
+
+func (s *SQLStore) ItemIDByName(name string) int { return Catalog.IDByName(name) }
+
+func (s *SQLStore) TrackedItems() []int { return Catalog.IDs() }
+
+func (s *SQLStore) FetchFailures(ctx context.Context, limit int) ([]FetchFailure, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT url, COALESCE(status_code, 0), attempts, COALESCE(error, ''), failed_at
+		FROM fetch_failures
+		ORDER BY failed_at DESC, id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failures := make([]FetchFailure, 0)
+	for rows.Next() {
+		var f FetchFailure
+		if err := rows.Scan(&f.URL, &f.StatusCode, &f.Attempts, &f.Error, &f.FailedAt); err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+func (s *SQLStore) JobRuns(ctx context.Context, job string, limit int) ([]JobRun, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT job, started_at, finished_at, duration_ms, COALESCE(error, '')
+		FROM job_runs
+		WHERE job = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, job, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]JobRun, 0)
+	for rows.Next() {
+		var run JobRun
+		if err := rows.Scan(&run.Job, &run.StartedAt, &run.FinishedAt, &run.DurationMs, &run.Error); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
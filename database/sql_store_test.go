@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestDB opens and migrates the database at dsn for one test and closes
+// it when the test ends
+func openTestDB(t *testing.T, dsn string) {
+	t.Helper()
+	Open(Options{DSN: dsn, BusyTimeout: 5 * time.Second, WAL: true, MaxReaders: 4})
+	t.Cleanup(Close)
+	if _, err := Migrate(context.Background()); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+}
+
+func TestSQLStoreSQLite(t *testing.T) {
+	openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+	testSQLStore(t)
+}
+
+// testSQLStore writes through the package's own writers and reads everything
+// back through SQLStore, so it runs unchanged against either dialect
+func testSQLStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLStore(ReadDB)
+	start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	if err := StoreItemCatalog(ctx, []Item{{ID: 2, Name: "Cannonball", Limit: 11000}, {ID: 560, Name: "Death rune"}}); err != nil {
+		t.Fatalf("storing catalog: %v", err)
+	}
+	if name := store.ItemName(560); name != "Death rune" {
+		t.Errorf("ItemName(560) = %q", name)
+	}
+	if id := store.ItemIDByName("cannonball"); id != 2 {
+		t.Errorf("ItemIDByName(cannonball) = %d, want 2", id)
+	}
+
+	var prices []PriceRow
+	for i := 0; i < 6; i++ {
+		at := start.Add(time.Duration(i) * time.Hour)
+		prices = append(prices, PriceRow{ItemID: 2, GameMode: "osrs", Timestamp: at, BuyPrice: 100 + i, SellPrice: 110 + i,
+			BuyTime: &at, SellTime: &at, Quality: "ok"})
+	}
+	prices = append(prices, PriceRow{ItemID: 2, GameMode: "dmm", Timestamp: start, BuyPrice: 300, SellPrice: 400, Quality: "ok"})
+	if n, err := ImportPrices(ctx, prices); err != nil || n != len(prices) {
+		t.Fatalf("importing prices: %d stored, %v", n, err)
+	}
+
+	history, err := store.PriceHistory(ctx, "osrs", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 6 || !history[0].Timestamp.Equal(start) || history[5].BuyPrice != 105 {
+		t.Errorf("unexpected price history %v", history)
+	}
+
+	candle := CandleRow{ItemID: 2, GameMode: "osrs", Timestamp: start.AddDate(0, 0, -1),
+		BuyOpen: 90, BuyHigh: 97, BuyLow: 88, BuyClose: 95, SellClose: 105, Ticks: 24}
+	if _, err := ImportCandles(ctx, "1d", []CandleRow{candle}); err != nil {
+		t.Fatal(err)
+	}
+	candles, err := store.Candles(ctx, "osrs", "1d", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 1 || candles[0].BuyHigh != 97 || candles[0].Ticks != 24 {
+		t.Errorf("unexpected candles %v", candles)
+	}
+
+	if _, err := DB.ExecContext(ctx, `
+		INSERT INTO item_averages_5m (item_id, game_mode, timestamp, avg_high_price, avg_low_price, high_price_volume, low_price_volume)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, 2, "osrs", start.Format(TimestampLayout), 110, 100, 50, 70); err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := store.RecentVolumes(ctx, "osrs", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if volumes[2] != (Volume{High: 50, Low: 70}) {
+		t.Errorf("volumes = %v", volumes)
+	}
+
+	if err := StoreItemAnalytics(ctx, DB, ItemAnalytics{ItemID: 2, GameMode: "osrs", SMA5Buy: 180, SMA5Sell: 200,
+		Buy: Indicators{RSI: 40}, Spread: Indicators{MACDLine: 1.5}}); err != nil {
+		t.Fatal(err)
+	}
+	analytics, err := store.Analytics(ctx, "osrs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analytics) != 1 || analytics[0].SMA5Sell != 200 || analytics[0].Buy.RSI != 40 || analytics[0].Spread.MACDLine != 1.5 {
+		t.Errorf("unexpected analytics %+v", analytics)
+	}
+
+	// The first three quotes are stored by 14:00. The main game has a daily
+	// candle, so its recomputed indicators are approximate.
+	asOf, approximate, err := store.AnalyticsAsOf(ctx, "osrs", start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(asOf) != 1 || asOf[0].SMA5Buy != 101 || asOf[0].SMA5Sell != 111 || !approximate {
+		t.Errorf("unexpected analytics as of 14:00 %+v, approximate %v", asOf, approximate)
+	}
+	// However late as_of is, every quote up to it is folded in
+	asOf, _, err = store.AnalyticsAsOf(ctx, "osrs", start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AnalyticsFromPrices("osrs", 2, []float64{100, 101, 102, 103, 104, 105}, []float64{110, 111, 112, 113, 114, 115})
+	if len(asOf) != 1 || asOf[0] != want {
+		t.Errorf("analytics a month later %+v, want %+v", asOf, want)
+	}
+	if asOf, approximate, err := store.AnalyticsAsOf(ctx, "dmm", start); err != nil || len(asOf) != 1 || approximate {
+		t.Errorf("as of in a mode without candles: %v, approximate %v, %v", asOf, approximate, err)
+	}
+	if _, _, err := store.AnalyticsAsOf(ctx, "osrs", start.Add(-time.Second)); !errors.Is(err, ErrBeforeHistory) {
+		t.Errorf("as of before the oldest quote: err = %v, want ErrBeforeHistory", err)
+	}
+	if asOf, _, err := store.AnalyticsAsOf(ctx, "fsw", start); err != nil || len(asOf) != 0 {
+		t.Errorf("as of in a mode without quotes: %v, %v", asOf, err)
+	}
+
+	if err := RecordFetchFailure(ctx, "https://example.com/latest", 503, 5, "Service Unavailable"); err != nil {
+		t.Fatal(err)
+	}
+	failures, err := store.FetchFailures(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0].StatusCode != 503 || failures[0].Attempts != 5 {
+		t.Errorf("unexpected fetch failures %v", failures)
+	}
+
+	if err := RecordJobRun(ctx, "latest-prices", start, start.Add(time.Second), errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := store.JobRuns(ctx, "latest-prices", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].DurationMs != 1000 || runs[0].Error != "boom" {
+		t.Errorf("unexpected job runs %v", runs)
+	}
+}
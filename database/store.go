@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Store is the storage the HTTP handlers read prices, analytics, the item
+// catalog and operational history through. SQLStore serves them from the
+// database and MemoryStore from maps filled in by the caller.
+type Store interface {
+	// PriceHistory returns an item's stored /latest quotes, oldest first
+	PriceHistory(ctx context.Context, mode string, itemID int) ([]PricePoint, error)
+	// Candles returns an item's candles of one resolution ("1h" or "1d"),
+	// oldest first
+	Candles(ctx context.Context, mode, resolution string, itemID int) ([]Candle, error)
+	// Averages returns an item's averaged buckets of one resolution ("5m", "1h"
+	// or "24h"), oldest first
+	Averages(ctx context.Context, mode, resolution string, itemID int) ([]Average, error)
+	// RecentVolumes returns the units traded per item in the 5-minute buckets
+	// starting at or after since and before until
+	RecentVolumes(ctx context.Context, mode string, since, until time.Time) (map[int]Volume, error)
+	// LatestGuidePrices returns the most recent guide price of every item
+	LatestGuidePrices(ctx context.Context) (map[int]GuidePrice, error)
+	// QuarantinedQuotes returns the most recent quarantined quotes, newest
+	// first; an itemID of 0 returns every item's
+	QuarantinedQuotes(ctx context.Context, mode string, itemID, limit int) ([]QuarantinedQuote, error)
+
+	// Analytics returns the latest analytics of every item in a game mode
+	Analytics(ctx context.Context, mode string) ([]ItemAnalytics, error)
+	// AnalyticsAsOf recomputes the analytics of every item in a game mode from
+	// all of its quotes stored up to asOf, by item ID. approximate is true when
+	// older quotes of the mode have been rolled into candles, so the indicators
+	// start at the oldest raw quote instead of each item's first. It returns
+	// ErrBeforeHistory when asOf is before the mode's oldest stored quote.
+	AnalyticsAsOf(ctx context.Context, mode string, asOf time.Time) (analytics []ItemAnalytics, approximate bool, err error)
+
+	// Item returns the catalog entry for an item
+	Item(itemID int) (Item, bool)
+	// ItemName returns an item's name, or a placeholder for unknown items
+	ItemName(itemID int) string
+	// ItemIDByName finds an item by name (case-insensitive), or returns -1
+	ItemIDByName(name string) int
+	// TrackedItems returns the IDs of every item in the catalog
+	TrackedItems() []int
+
+	// FetchFailures returns the most recent failed upstream requests, newest first
+	FetchFailures(ctx context.Context, limit int) ([]FetchFailure, error)
+	// JobRuns returns the most recent runs of a job, newest first
+	JobRuns(ctx context.Context, job string, limit int) ([]JobRun, error)
+
+	// ExportPrices calls fn with every stored quote the filter selects, by
+	// item and then time, stopping at the first error
+	ExportPrices(ctx context.Context, filter ExportFilter, fn func(PriceRow) error) error
+	// ExportAnalytics calls fn with the analytics of every item the filter
+	// selects, whose time range applies to when they were last updated
+	ExportAnalytics(ctx context.Context, filter ExportFilter, fn func(AnalyticsRow) error) error
+	// ExportCandles calls fn with every candle of one resolution ("1h" or
+	// "1d") the filter selects, by item and then time
+	ExportCandles(ctx context.Context, resolution string, filter ExportFilter, fn func(CandleRow) error) error
+}
+
+// ErrBeforeHistory is returned by AnalyticsAsOf for a time before the oldest
+// stored quote: older quotes have been rolled into candles or never existed.
+var ErrBeforeHistory = errors.New("no quotes are stored from before that time")
+
+// PricePoint is one stored /latest quote of an item
+type PricePoint struct {
+	Timestamp time.Time
+	BuyPrice  int
+	SellPrice int
+}
+
+// Average is one averaged price bucket of an item. A price of 0 means that
+// side did not trade in the bucket.
+type Average struct {
+	Timestamp       time.Time
+	AvgHighPrice    int
+	AvgLowPrice     int
+	HighPriceVolume int
+	LowPriceVolume  int
+}
+
+// Volume is the number of units of an item traded at the high and low price
+type Volume struct {
+	High int
+	Low  int
+}
+
+// QuarantinedQuote is a quote the data-quality gate kept out of item_prices.
+// Trade times are empty for a side that has never traded.
+type QuarantinedQuote struct {
+	ItemID        int    `json:"item_id"`
+	BuyPrice      int    `json:"buy_price"`
+	SellPrice     int    `json:"sell_price"`
+	BuyTime       string `json:"buy_time"`
+	SellTime      string `json:"sell_time"`
+	Reason        string `json:"reason"`
+	QuarantinedAt string `json:"quarantined_at"`
+}
+
+// FetchFailure is an upstream request that failed after all retries
+type FetchFailure struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"` // 0 when no HTTP response was received
+	Attempts   int    `json:"attempts"`
+	Error      string `json:"error"`
+	FailedAt   string `json:"failed_at"`
+}
+
+var (
+	_ Store = (*SQLStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -1,10 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"flipAssistant/bulk"
+	"flipAssistant/config"
 	"flipAssistant/database"
 	"flipAssistant/routes"
+	"flipAssistant/scheduler"
 	"flipAssistant/scripts"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -12,27 +26,103 @@ import (
 )
 
 func main() {
-	// Initialize the database
-	database.InitDB()
+	// Cancelled on Ctrl+C or SIGTERM so in-flight work can finish or roll back
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Load items data from JSON
-	if err := database.LoadItemsData(); err != nil {
-		log.Printf("Warning: Could not load items data: %v", err)
+	cfg := config.Load()
+	for _, modes := range cfg.JobModes {
+		for _, mode := range modes {
+			checkGameMode(mode)
+		}
+	}
+	scripts.AnalyticsWorkers = cfg.AnalyticsWorkers
+	scripts.Quality = scripts.QualityRules{
+		RejectMissingSide: cfg.QualityRejectMissingSide,
+		MaxJump:           cfg.QualityMaxJump,
+		MedianWindow:      cfg.QualityMedianWindow,
+		MaxQuoteAge:       cfg.QualityMaxAge,
 	}
 
-	// Get all tradeable items to track (comprehensive coverage)
-	tradeableItems := database.GetAllTradeableItems()
-	log.Printf("Tracking %d tradeable items for flip opportunities", len(tradeableItems))
+	dbOptions := database.Options{
+		DSN:         cfg.DatabaseDSN,
+		BusyTimeout: cfg.DatabaseBusyTimeout,
+		WAL:         cfg.DatabaseWAL,
+		MaxReaders:  cfg.DatabaseReaders,
+	}
 
-	// Fetch prices for all items in a single API call (API-friendly)
-	go func() {
-		for {
-			log.Println("Fetching prices for all tracked items...")
-			scripts.FetchAndStorePricesForAllItems(tradeableItems)
+	// migrate manages the schema itself, so it runs before InitDB applies migrations
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(ctx, dbOptions, os.Args[2:])
+		return
+	}
+
+	// Initialize and migrate the database; closing it cleanly leaves no journal behind
+	database.InitDB(dbOptions)
+	defer database.Close()
+
+	// Load the item catalog from the items file if one is configured, else
+	// from the database, downloading it from /mapping on first run
+	if cfg.ItemsFile != "" {
+		if err := scripts.ImportItemsFile(ctx, cfg.ItemsFile); err != nil {
+			log.Printf("Warning: Could not import items file: %v", err)
+		}
+	}
+	if database.Catalog.Len() == 0 {
+		if err := database.Catalog.Load(ctx); err != nil {
+			log.Printf("Item catalog not loaded (%v), fetching /mapping...", err)
+			if err := scripts.RefreshItemCatalog(ctx, cfg.WikiBaseURL); err != nil {
+				log.Printf("Warning: Could not load items data: %v", err)
+			}
+		}
+	}
+
+	// Subcommands run once and exit; with no arguments we start the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backfill":
+			runBackfill(ctx, cfg.WikiBaseURL, os.Args[2:])
+		case "reprocess":
+			runReprocess(ctx, os.Args[2:])
+		case "rebuild-analytics":
+			runRebuildAnalytics(ctx, os.Args[2:])
+		case "export":
+			runExport(ctx, os.Args[2:])
+		case "import":
+			runImport(ctx, os.Args[2:])
+		default:
+			log.Fatalf("Unknown command %q (available: backfill, reprocess, rebuild-analytics, export, import, migrate)", os.Args[1])
+		}
+		return
+	}
+
+	if err := runServer(ctx, cfg); err != nil {
+		database.Close()
+		log.Fatalf("Server error: %v", err)
+	}
+}
 
-			// Wait 10 minutes between batch updates to be respectful to the API
-			// This means we update all 141 items with just 1 API call every 10 minutes
-			time.Sleep(10 * time.Minute)
+// runServer starts the background jobs and the HTTP API, and shuts both down
+// gracefully once ctx is cancelled or the HTTP server fails, returning the
+// server's error in the latter case
+func runServer(ctx context.Context, cfg config.Config) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	log.Printf("Using %s price source", cfg.PriceSource)
+
+	// Track all tradeable items (comprehensive coverage)
+	log.Printf("Tracking %d tradeable items for flip opportunities", len(database.GetAllTradeableItems()))
+
+	jobs := newScheduler(cfg)
+	jobs.Start(ctx)
+
+	// Pick up edits to the items file without a restart
+	watcherDone := make(chan struct{})
+	go func() {
+		defer close(watcherDone)
+		if cfg.ItemsFile != "" {
+			scripts.WatchItemsFile(ctx, cfg.ItemsFile, cfg.ItemsFilePoll)
 		}
 	}()
 
@@ -42,15 +132,492 @@ func main() {
 	// Add CORS middleware
 	r.Use(cors.Default())
 
-	// Define API routes
-	r.GET("/item-history/:id", routes.GetItemHistory)
-	r.GET("/suggest-flips", routes.SuggestFlips)
-	r.GET("/categorized-flips", routes.GetCategorizedFlips)
-	r.GET("/item-info/:id", routes.GetItemInfo)
-	r.GET("/tracked-items", routes.GetAllTrackedItems)
-	r.GET("/search-item", routes.SearchItemByName)
+	// Define API routes; handlers read through the read-only pool
+	store := database.NewSQLStore(database.ReadDB)
+	r.GET("/item-history/:id", routes.GetItemHistory(store))
+	r.GET("/suggest-flips", routes.SuggestFlips(store))
+	r.GET("/categorized-flips", routes.GetCategorizedFlips(store))
+	r.GET("/price-divergence", routes.GetPriceDivergence(store, cfg.DivergenceThreshold))
+	r.GET("/item-info/:id", routes.GetItemInfo(store))
+	r.GET("/tracked-items", routes.GetAllTrackedItems(store))
+	r.GET("/search-item", routes.SearchItemByName(store))
+	r.GET("/fetch-failures", routes.GetFetchFailures(store))
+	r.GET("/quarantine", routes.GetQuarantine(store))
+	r.GET("/jobs", routes.GetJobs(jobs))
+	r.GET("/jobs/:name/runs", routes.GetJobRuns(store))
+	r.GET("/export/:dataset", routes.ExportData(store))
+
+	// Routes that run jobs or change stored data need the admin token, and are
+	// left out without one; the import command and items file watcher work
+	// either way
+	if cfg.AdminToken != "" {
+		admin := r.Group("/", routes.RequireAdminToken(cfg.AdminToken))
+		admin.POST("/jobs/:name/run", routes.TriggerJob(jobs))
+		admin.POST("/import/:dataset", routes.ImportData(cfg.MaxImportSize))
+		admin.POST("/admin/reload-items", routes.ReloadItemCatalog(database.Catalog, func(ctx context.Context) error {
+			if cfg.ItemsFile != "" {
+				return scripts.ImportItemsFile(ctx, cfg.ItemsFile)
+			}
+			return database.Catalog.Load(ctx)
+		}))
+	} else {
+		log.Println("FLIP_ADMIN_TOKEN is not set, so POST /jobs/:name/run, /import and /admin/reload-items are disabled")
+	}
 
 	// Start server
-	log.Println("Server running on :8080")
-	r.Run(":8080")
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Println("Server running on :8080")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down: finishing in-flight requests and jobs...")
+	case err = <-serverErr:
+		log.Printf("Server failed (%v), stopping jobs...", err)
+	}
+	cancel()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+
+	// Jobs saw ctx cancelled too; wait for their writes to finish or roll back
+	jobs.Wait()
+	<-watcherDone
+	log.Println("Shutdown complete")
+	return err
+}
+
+// newScheduler registers the background jobs. Jobs re-read the catalog on
+// every run so items added by a mapping refresh are picked up. Price jobs are
+// registered once per configured game mode.
+func newScheduler(cfg config.Config) *scheduler.Scheduler {
+	s := scheduler.New()
+
+	// Fetch prices for all items in a single API call (API-friendly), every
+	// 10 minutes by default to be respectful to the API
+	for _, mode := range cfg.JobModes["latest-prices"] {
+		mode := mode
+		source, err := newPriceSource(cfg, mode)
+		if err != nil {
+			log.Fatalf("Could not create %s price source for %s: %v", cfg.PriceSource, mode, err)
+		}
+		s.Register(scheduler.Job{
+			Name:       jobName("latest-prices", mode),
+			Interval:   cfg.FetchInterval,
+			Jitter:     cfg.JobJitter,
+			RunOnStart: true,
+			Run: func(ctx context.Context) error {
+				err := scripts.FetchAndStorePricesForAllItems(ctx, source, mode, database.GetAllTradeableItems())
+				if errors.Is(err, io.EOF) {
+					// A replay or archive source has run out of snapshots
+					return fmt.Errorf("%w: %v", scheduler.ErrDone, err)
+				}
+				return err
+			},
+		})
+	}
+
+	// Averaged prices and volumes; the API publishes a new 5m bucket every 5 minutes
+	averages := []struct {
+		resolution string
+		interval   time.Duration
+	}{
+		{"5m", cfg.Averages5mInterval},
+		{"1h", cfg.Averages1hInterval},
+		{"24h", cfg.Averages24hInterval},
+	}
+	for _, avg := range averages {
+		for _, mode := range cfg.JobModes["averages-"+avg.resolution] {
+			resolution, mode := avg.resolution, mode
+			s.Register(scheduler.Job{
+				Name:       jobName("averages-"+resolution, mode),
+				Interval:   avg.interval,
+				Jitter:     cfg.JobJitter,
+				RunOnStart: true,
+				Run: func(ctx context.Context) error {
+					return scripts.FetchAverages(ctx, cfg.WikiBaseURL, mode, resolution, database.GetAllTradeableItems())
+				},
+			})
+		}
+	}
+
+	// Refresh the item catalog for new items and buy limit changes; it was
+	// already loaded at startup. An items file replaces /mapping and is
+	// watched for changes instead.
+	if cfg.ItemsFile == "" {
+		s.Register(scheduler.Job{
+			Name:     "mapping-refresh",
+			Interval: cfg.MappingInterval,
+			Jitter:   cfg.JobJitter,
+			Run: func(ctx context.Context) error {
+				return scripts.RefreshItemCatalog(ctx, cfg.WikiBaseURL)
+			},
+		})
+	}
+
+	// Official guide prices to cross-check the wiki against; the itemdb only
+	// updates once a day and serves one item per request
+	guide := scripts.NewJagexSource(cfg.ItemDBBaseURL, cfg.GuideDelay)
+	s.Register(scheduler.Job{
+		Name:       "guide-prices",
+		Interval:   cfg.GuideInterval,
+		Jitter:     cfg.JobJitter,
+		RunOnStart: true,
+		Run: func(ctx context.Context) error {
+			return scripts.FetchAndStoreGuidePrices(ctx, guide, database.GetAllTradeableItems())
+		},
+	})
+
+	s.Register(scheduler.Job{
+		Name:       "retention",
+		Interval:   cfg.RetentionInterval,
+		Jitter:     cfg.JobJitter,
+		RunOnStart: true,
+		Run: func(ctx context.Context) error {
+			deleted, err := database.PruneOperationalData(ctx, cfg.LogRetention, cfg.SnapshotRetention)
+			if err != nil {
+				return err
+			}
+			log.Printf("Retention removed %d rows", deleted)
+
+			compacted, err := database.CompactPrices(ctx, cfg.PriceRetention, cfg.HourlyCandleRetention)
+			if err != nil {
+				return err
+			}
+			log.Printf("Retention rolled %d price ticks into candles", compacted)
+			return nil
+		},
+	})
+
+	return s
+}
+
+// jobName names a price job after its game mode; the main game keeps the plain
+// name so its run history carries over
+func jobName(base, mode string) string {
+	if mode == database.DefaultGameMode {
+		return base
+	}
+	return base + "-" + mode
+}
+
+// newPriceSource builds the /latest price source selected by the configuration
+// for one game mode
+func newPriceSource(cfg config.Config, mode string) (scripts.PriceSource, error) {
+	switch cfg.PriceSource {
+	case "live":
+		return scripts.NewLiveSource(cfg.WikiBaseURL, mode), nil
+	case "fixture":
+		return scripts.NewFixtureSource(cfg.FixturePath), nil
+	case "replay":
+		return scripts.NewReplaySource(cfg.ReplayDir, cfg.ReplaySpeed)
+	case "archive":
+		return scripts.NewArchiveSource(mode, time.Time{}, time.Time{}), nil
+	default:
+		return nil, fmt.Errorf("unknown price source %q (expected live, fixture, replay or archive)", cfg.PriceSource)
+	}
+}
+
+// runBackfill seeds item_prices from the /timeseries endpoint, e.g.
+//
+//	go run . backfill -items 2,560 -timestep 5m
+//	go run . backfill -all -timestep 6h -mode dmm
+func runBackfill(ctx context.Context, baseURL string, args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	items := fs.String("items", "", "comma-separated item IDs to backfill")
+	all := fs.Bool("all", false, "backfill every tradeable item")
+	timestep := fs.String("timestep", "5m", "timeseries resolution: 5m, 1h or 6h")
+	delay := fs.Duration("delay", time.Second, "pause between items to respect the API")
+	mode := fs.String("mode", database.DefaultGameMode, "game mode market: osrs, dmm or fsw")
+	fs.Parse(args)
+	checkGameMode(*mode)
+
+	var itemIDs []int
+	if *all {
+		itemIDs = database.GetAllTradeableItems()
+	} else {
+		ids, err := parseItemIDs(*items)
+		if err != nil {
+			log.Fatal(err)
+		}
+		itemIDs = ids
+	}
+	if len(itemIDs) == 0 {
+		log.Fatal("No items to backfill: pass -items or -all")
+	}
+
+	if err := scripts.BackfillItemHistory(ctx, baseURL, *mode, itemIDs, *timestep, *delay); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runReprocess rebuilds item_prices and item_analytics from archived snapshots, e.g.
+//
+//	go run . reprocess -items 2,560 -reset
+//	go run . reprocess -since 2026-01-01 -until 2026-02-01 -mode dmm
+func runReprocess(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	items := fs.String("items", "", "comma-separated item IDs to rebuild (default: every tradeable item)")
+	since := fs.String("since", "", "only replay snapshots fetched at or after this date (YYYY-MM-DD or RFC 3339)")
+	until := fs.String("until", "", "only replay snapshots fetched before this date (YYYY-MM-DD or RFC 3339)")
+	reset := fs.Bool("reset", false, "delete the items' existing prices and analytics before rebuilding")
+	mode := fs.String("mode", database.DefaultGameMode, "game mode market: osrs, dmm or fsw")
+	fs.Parse(args)
+	checkGameMode(*mode)
+
+	itemIDs, err := parseItemIDs(*items)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(itemIDs) == 0 {
+		itemIDs = database.GetAllTradeableItems()
+	}
+
+	sinceTime, err := parseDate(*since)
+	if err != nil {
+		log.Fatal(err)
+	}
+	untilTime, err := parseDate(*until)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := scripts.ReprocessArchive(ctx, *mode, itemIDs, sinceTime, untilTime, *reset); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runRebuildAnalytics re-derives the stored indicator state and analytics from
+// the raw price history, e.g.
+//
+//	go run . rebuild-analytics
+//	go run . rebuild-analytics -items 2,560 -mode dmm
+//
+// Quotes the retention job rolled into candles are not raw history any more,
+// and rebuilding an item that has some would restart its indicators at its
+// oldest remaining quote. Such items are refused unless -force is given.
+func runRebuildAnalytics(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("rebuild-analytics", flag.ExitOnError)
+	items := fs.String("items", "", "comma-separated item IDs to rebuild (default: every item with stored prices)")
+	mode := fs.String("mode", database.DefaultGameMode, "game mode market: osrs, dmm or fsw")
+	force := fs.Bool("force", false, "also rebuild items whose older quotes were rolled into candles, from their remaining raw quotes only (refused otherwise)")
+	fs.Parse(args)
+	checkGameMode(*mode)
+
+	itemIDs, err := parseItemIDs(*items)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(itemIDs) == 0 {
+		if itemIDs, err = database.PricedItems(ctx, *mode); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if !*force {
+		compacted, err := database.CompactedItems(ctx, *mode, itemIDs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(compacted) > 0 {
+			log.Fatalf("%d of %d items have quotes rolled into candles (e.g. item %d), so a rebuild would drop that part of their history; "+
+				"use -items to leave them out or -force to rebuild them from their remaining raw quotes", len(compacted), len(itemIDs), compacted[0])
+		}
+	}
+
+	start := time.Now()
+	rebuilt, err := scripts.RebuildAnalytics(ctx, *mode, itemIDs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Rebuilt analytics for %d of %d items in %v", rebuilt, len(itemIDs), time.Since(start).Round(time.Millisecond))
+}
+
+// runExport writes a dataset to a CSV, JSON Lines or Parquet file, e.g.
+//
+//	go run . export -dataset prices -items 2,560 -since 2026-01-01 -out prices.parquet
+//	go run . export -dataset analytics -format jsonl > analytics.jsonl
+func runExport(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dataset := fs.String("dataset", "prices", "what to export: "+strings.Join(bulk.Datasets, ", "))
+	format := fs.String("format", "", "csv, jsonl or parquet (default: from the -out extension, else csv)")
+	out := fs.String("out", "-", "file to write, or - for standard output")
+	items := fs.String("items", "", "comma-separated item IDs to export (default: every item)")
+	since := fs.String("since", "", "only rows at or after this date (YYYY-MM-DD or RFC 3339)")
+	until := fs.String("until", "", "only rows before this date (YYYY-MM-DD or RFC 3339)")
+	mode := fs.String("mode", database.DefaultGameMode, "game mode market: osrs, dmm or fsw")
+	fs.Parse(args)
+
+	filter, err := bulk.ParseFilter(*mode, *items, *since, *until)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *format == "" {
+		*format = bulk.FormatForPath(*out)
+		if *format == "" {
+			*format = "csv"
+		}
+	}
+	if err := bulk.CheckDataset(*dataset); err != nil {
+		log.Fatal(err)
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	n, err := bulk.Export(ctx, database.NewSQLStore(database.ReadDB), w, *dataset, *format, filter)
+	if err != nil {
+		log.Fatalf("Export failed after %d rows: %v", n, err)
+	}
+	log.Printf("Exported %d %s rows", n, *dataset)
+}
+
+// runImport merges a dataset from an exported file, or straight from another
+// database, skipping rows that are already stored, e.g.
+//
+//	go run . import -dataset prices -in prices.parquet
+//	go run . import -dataset candles_1d -db /backups/other/flips.db -mode dmm
+func runImport(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dataset := fs.String("dataset", "prices", "what to import: "+strings.Join(bulk.Datasets, ", "))
+	format := fs.String("format", "", "csv, jsonl or parquet (default: from the -in extension, else csv)")
+	in := fs.String("in", "-", "file to read, or - for standard input")
+	db := fs.String("db", "", "merge from this database (SQLite file or postgres:// URL) instead of a file")
+	items := fs.String("items", "", "with -db, comma-separated item IDs to merge (default: every item)")
+	since := fs.String("since", "", "with -db, only rows at or after this date (YYYY-MM-DD or RFC 3339)")
+	until := fs.String("until", "", "with -db, only rows before this date (YYYY-MM-DD or RFC 3339)")
+	mode := fs.String("mode", database.DefaultGameMode, "with -db, game mode market: osrs, dmm or fsw")
+	fs.Parse(args)
+
+	if err := bulk.CheckDataset(*dataset); err != nil {
+		log.Fatal(err)
+	}
+
+	if *db != "" {
+		filter, err := bulk.ParseFilter(*mode, *items, *since, *until)
+		if err != nil {
+			log.Fatal(err)
+		}
+		source, err := database.OpenReadOnly(*db)
+		if err != nil {
+			log.Fatalf("Error opening %s: %v", *db, err)
+		}
+		defer source.Close()
+		if _, err := bulk.Merge(ctx, database.NewSQLStore(source), *dataset, filter); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *format == "" {
+		*format = bulk.FormatForPath(*in)
+		if *format == "" {
+			*format = "csv"
+		}
+	}
+
+	r := os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if _, err := bulk.Import(ctx, r, *dataset, *format); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runMigrate applies pending schema migrations, or lists them with -status, e.g.
+//
+//	go run . migrate
+//	go run . migrate -status
+func runMigrate(ctx context.Context, opts database.Options, args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	status := fs.Bool("status", false, "list migrations and whether they are applied, without applying any")
+	fs.Parse(args)
+
+	database.Open(opts)
+	defer database.Close()
+
+	if *status {
+		statuses, err := database.MigrationStatuses(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(database.TimestampLayout)
+			}
+			fmt.Printf("%04d  %-30s  %s\n", s.Version, s.Name, state)
+		}
+		return
+	}
+
+	applied, err := database.Migrate(ctx)
+	if err != nil {
+		log.Fatalf("Database migration failed: %v", err)
+	}
+	if len(applied) == 0 {
+		log.Println("Schema is up to date")
+	}
+}
+
+// checkGameMode exits if mode is not a market served by the prices API
+func checkGameMode(mode string) {
+	if !database.GameModes[mode] {
+		log.Fatalf("Unknown game mode %q (expected osrs, dmm or fsw)", mode)
+	}
+}
+
+// parseDate parses a YYYY-MM-DD or RFC 3339 time; an empty string gives the zero time
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
+
+// parseItemIDs parses a comma-separated list of item IDs
+func parseItemIDs(list string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flipAssistant/config"
+	"testing"
+)
+
+func TestMappingRefreshWithoutItemsFile(t *testing.T) {
+	tests := []struct {
+		itemsFile string
+		want      bool
+	}{
+		{"", true},
+		{"items.json", false},
+	}
+
+	for _, tt := range tests {
+		cfg := config.Load()
+		cfg.PriceSource = "live"
+		cfg.ItemsFile = tt.itemsFile
+
+		registered := false
+		for _, st := range newScheduler(cfg).Status() {
+			registered = registered || st.Name == "mapping-refresh"
+		}
+		if registered != tt.want {
+			t.Errorf("items file %q: mapping-refresh registered %v, want %v", tt.itemsFile, registered, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdminToken rejects requests that don't send token as an
+// "Authorization: Bearer" header. Browsers preflight that header and the
+// default CORS policy doesn't allow it, so other sites can't send it either.
+func RequireAdminToken(token string) gin.HandlerFunc {
+	want := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), want) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
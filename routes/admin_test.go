@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// postImport sends body to a token-protected /import limited to 16 bytes and
+// returns the status code
+func postImport(t *testing.T, url, auth, body string) int {
+	t.Helper()
+	r := gin.New()
+	r.POST("/import/:dataset", RequireAdminToken("s3cret"), ImportData(16))
+
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRequireAdminToken(t *testing.T) {
+	body := `{"item_id":2,"game_mode":"osrs"}` + "\n"
+	for auth, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusRequestEntityTooLarge,
+	} {
+		if code := postImport(t, "/import/prices?format=jsonl", auth, body); code != want {
+			t.Errorf("Authorization %q: status = %d, want %d", auth, code, want)
+		}
+	}
+}
+
+func TestImportDataTooLarge(t *testing.T) {
+	for format, body := range map[string]string{
+		"csv":     "item_id,game_mode,timestamp\n2,osrs,2026-03-14T12:00:00Z\n",
+		"jsonl":   `{"item_id":2,"game_mode":"osrs"}` + "\n",
+		"parquet": strings.Repeat("PAR1", 8),
+	} {
+		if code := postImport(t, "/import/prices?format="+format, "Bearer s3cret", body); code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: status = %d, want 413", format, code)
+		}
+	}
+}
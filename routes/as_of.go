@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"errors"
+	"flipAssistant/database"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flipAnalytics returns the analytics the flip lists are ranked by: the latest
+// stored values, or with ?as_of= values recomputed from the quotes stored up to
+// that time. asOf is zero without ?as_of=; approximate is true when retention
+// has compacted older quotes, so the recomputed indicators start later than
+// the stored ones did. Errors are answered and ok is false; an as_of before the
+// oldest stored quote is a bad request.
+func flipAnalytics(c *gin.Context, store database.Store, mode string) (analytics []database.ItemAnalytics, asOf time.Time, approximate, ok bool) {
+	asOf, ok = asOfTime(c)
+	if !ok {
+		return nil, asOf, false, false
+	}
+
+	var err error
+	if asOf.IsZero() {
+		analytics, err = store.Analytics(c.Request.Context(), mode)
+	} else {
+		analytics, approximate, err = store.AnalyticsAsOf(c.Request.Context(), mode, asOf)
+	}
+	if errors.Is(err, database.ErrBeforeHistory) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid as_of, " + err.Error()})
+		return nil, asOf, false, false
+	}
+	if err != nil {
+		log.Printf("Query error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+		return nil, asOf, false, false
+	}
+	return analytics, asOf, approximate, true
+}
+
+// asOfTime reads the optional ?as_of= query parameter as RFC 3339, the
+// database's UTC "2006-01-02 15:04:05" form or unix seconds. It returns the
+// zero time when the parameter is absent; an invalid one is answered with 400
+// and ok is false.
+func asOfTime(c *gin.Context) (asOf time.Time, ok bool) {
+	value := c.Query("as_of")
+	if value == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(database.TimestampLayout, value); err == nil {
+		return t, true
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil && unix > 0 {
+		return time.Unix(unix, 0), true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid as_of, expected RFC 3339 or unix seconds"})
+	return time.Time{}, false
+}
@@ -1,15 +1,17 @@
 package routes
 
 import (
-	"database/sql"
 	"flipAssistant/database"
-	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// flipLimit is how many items each flip list returns
+const flipLimit = 10
+
 // FlipCategory represents different types of flip opportunities
 type FlipCategory struct {
 	Name        string                   `json:"name"`
@@ -18,209 +20,165 @@ type FlipCategory struct {
 	Count       int                      `json:"count"`
 }
 
-// GetCategorizedFlips returns flip suggestions organized by categories
-func GetCategorizedFlips(c *gin.Context) {
-	categories := []FlipCategory{
-		{
-			Name:        "High Value Items",
-			Description: "Items worth 1M+ GP - High profit potential but requires significant capital",
-			Items:       getFlipsByValueRange(1000000, 999999999),
-			Count:       0,
-		},
-		{
-			Name:        "Mid Value Items",
-			Description: "Items worth 100K-1M GP - Good balance of profit and accessibility",
-			Items:       getFlipsByValueRange(100000, 999999),
-			Count:       0,
-		},
-		{
-			Name:        "Budget Items",
-			Description: "Items worth less than 100K GP - Low capital required, great for beginners",
-			Items:       getFlipsByValueRange(0, 99999),
-			Count:       0,
-		},
-		{
-			Name:        "High Margin Items",
-			Description: "Items with profit margin >5% of item value - Percentage-based profits",
-			Items:       getFlipsByMarginPercentage(5.0),
-			Count:       0,
-		},
-		{
-			Name:        "High Volume Potential",
-			Description: "Items with high GE buy limits - Suitable for bulk trading",
-			Items:       getFlipsByBuyLimit(),
-			Count:       0,
-		},
-		{
-			Name:        "Quick Flips",
-			Description: "Items with consistent small margins - Fast turnover opportunities",
-			Items:       getFlipsByConsistency(),
-			Count:       0,
-		},
-	}
+// GetCategorizedFlips returns flip suggestions organized by categories, for the
+// game mode selected by ?mode=, with the RSI and MACD of the ?series= price.
+// With ?as_of= the categories are ranked by the analytics of that time.
+func GetCategorizedFlips(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mode, ok := gameMode(c)
+		if !ok {
+			return
+		}
+		series, ok := indicatorSeries(c)
+		if !ok {
+			return
+		}
 
-	// Set count for each category
-	for i := range categories {
-		categories[i].Count = len(categories[i].Items)
-	}
+		analytics, asOf, approximate, ok := flipAnalytics(c, store, mode)
+		if !ok {
+			return
+		}
 
-	c.JSON(http.StatusOK, gin.H{
-		"categories": categories,
-		"timestamp":  time.Now().Unix(),
-	})
-}
+		categories := []FlipCategory{
+			{
+				Name:        "High Value Items",
+				Description: "Items worth 1M+ GP - High profit potential but requires significant capital",
+				Items:       getFlipsByValueRange(store, analytics, series, 1000000, 999999999),
+				Count:       0,
+			},
+			{
+				Name:        "Mid Value Items",
+				Description: "Items worth 100K-1M GP - Good balance of profit and accessibility",
+				Items:       getFlipsByValueRange(store, analytics, series, 100000, 999999),
+				Count:       0,
+			},
+			{
+				Name:        "Budget Items",
+				Description: "Items worth less than 100K GP - Low capital required, great for beginners",
+				Items:       getFlipsByValueRange(store, analytics, series, 0, 99999),
+				Count:       0,
+			},
+			{
+				Name:        "High Margin Items",
+				Description: "Items with profit margin >5% of item value - Percentage-based profits",
+				Items:       getFlipsByMarginPercentage(store, analytics, series, 5.0),
+				Count:       0,
+			},
+			{
+				Name:        "High Volume Potential",
+				Description: "Items with high GE buy limits - Suitable for bulk trading",
+				Items:       getFlipsByBuyLimit(store, analytics, series),
+				Count:       0,
+			},
+			{
+				Name:        "Quick Flips",
+				Description: "Items with consistent small margins - Fast turnover opportunities",
+				Items:       getFlipsByConsistency(store, analytics, series),
+				Count:       0,
+			},
+		}
 
-// getFlipsByValueRange returns flips within a specific price range
-func getFlipsByValueRange(minPrice, maxPrice int) []map[string]interface{} {
-	rows, err := database.DB.Query(`
-		SELECT ia.item_id, ia.sma5_buy, ia.sma5_sell, (ia.sma5_sell - ia.sma5_buy) as profit_margin
-		FROM item_analytics ia
-		WHERE ia.sma5_buy BETWEEN ? AND ?
-		AND ia.sma5_buy > 0 AND ia.sma5_sell > 0
-		AND (ia.sma5_sell - ia.sma5_buy) > 0
-		ORDER BY profit_margin DESC
-		LIMIT 10
-	`, minPrice, maxPrice)
-
-	if err != nil {
-		return []map[string]interface{}{}
+		// Set count for each category
+		for i := range categories {
+			categories[i].Count = len(categories[i].Items)
+		}
+
+		response := gin.H{
+			"categories": categories,
+			"timestamp":  time.Now().Unix(),
+			"mode":       mode,
+			"series":     series,
+		}
+		if !asOf.IsZero() {
+			response["timestamp"] = asOf.Unix()
+			response["as_of"] = asOf.UTC().Format(time.RFC3339)
+			response["approximate"] = approximate
+		}
+		c.JSON(http.StatusOK, response)
 	}
-	defer rows.Close()
+}
 
-	return processFlipRows(rows)
+// getFlipsByValueRange returns flips within a specific price range
+func getFlipsByValueRange(store database.Store, analytics []database.ItemAnalytics, series string, minPrice, maxPrice float64) []map[string]interface{} {
+	return flipItems(store, topFlips(analytics, func(a database.ItemAnalytics) bool {
+		return a.SMA5Buy >= minPrice && a.SMA5Buy <= maxPrice && profitMargin(a) > 0
+	}, profitMargin), series, false)
 }
 
 // getFlipsByMarginPercentage returns flips with high percentage margins
-func getFlipsByMarginPercentage(minPercentage float64) []map[string]interface{} {
-	rows, err := database.DB.Query(`
-		SELECT ia.item_id, ia.sma5_buy, ia.sma5_sell, (ia.sma5_sell - ia.sma5_buy) as profit_margin,
-		       ((ia.sma5_sell - ia.sma5_buy) / ia.sma5_buy * 100) as margin_percentage
-		FROM item_analytics ia
-		WHERE ia.sma5_buy > 0 AND ia.sma5_sell > 0
-		AND ((ia.sma5_sell - ia.sma5_buy) / ia.sma5_buy * 100) >= ?
-		AND (ia.sma5_sell - ia.sma5_buy) > 0
-		ORDER BY margin_percentage DESC
-		LIMIT 10
-	`, minPercentage)
-
-	if err != nil {
-		return []map[string]interface{}{}
-	}
-	defer rows.Close()
-
-	return processFlipRowsWithPercentage(rows)
+func getFlipsByMarginPercentage(store database.Store, analytics []database.ItemAnalytics, series string, minPercentage float64) []map[string]interface{} {
+	return flipItems(store, topFlips(analytics, func(a database.ItemAnalytics) bool {
+		return marginPercentage(a) >= minPercentage && profitMargin(a) > 0
+	}, marginPercentage), series, true)
 }
 
-// getFlipsByBuyLimit returns items with high buy limits (approximated by item category)
-func getFlipsByBuyLimit() []map[string]interface{} {
-	// Items that typically have high buy limits in OSRS
-	highLimitItems := []int{
-		// Consumables (high limits)
-		2, 560, 561, 562, 563, 564, 565, // Cannonballs and runes
-		384, 386, 373, 361, // Food items
-		199, 201, 203, 205, 207, 209, 211, 213, 215, 217, 219, // Herbs
-		440, 442, 444, 447, 449, 451, 453, // Ores
-		2349, 2351, 2353, 2355, 2357, 2359, 2361, 2363, // Bars
+// getFlipsByBuyLimit returns profitable items with high GE buy limits
+func getFlipsByBuyLimit(store database.Store, analytics []database.ItemAnalytics, series string) []map[string]interface{} {
+	buyLimit := func(a database.ItemAnalytics) int {
+		item, _ := store.Item(a.ItemID)
+		return item.Limit
 	}
-
-	if len(highLimitItems) == 0 {
-		return []map[string]interface{}{}
-	}
-
-	// Create placeholders for the IN clause
-	placeholders := "?"
-	for i := 1; i < len(highLimitItems); i++ {
-		placeholders += ",?"
-	}
-
-	query := fmt.Sprintf(`
-		SELECT ia.item_id, ia.sma5_buy, ia.sma5_sell, (ia.sma5_sell - ia.sma5_buy) as profit_margin
-		FROM item_analytics ia
-		WHERE ia.item_id IN (%s)
-		AND ia.sma5_buy > 0 AND ia.sma5_sell > 0
-		AND (ia.sma5_sell - ia.sma5_buy) > 0
-		ORDER BY profit_margin DESC
-		LIMIT 10
-	`, placeholders)
-
-	// Convert int slice to interface slice for query args
-	args := make([]interface{}, len(highLimitItems))
-	for i, v := range highLimitItems {
-		args[i] = v
-	}
-
-	rows, err := database.DB.Query(query, args...)
-	if err != nil {
-		return []map[string]interface{}{}
-	}
-	defer rows.Close()
-
-	return processFlipRows(rows)
+	return flipItems(store, topFlips(analytics, func(a database.ItemAnalytics) bool {
+		return buyLimit(a) >= 5000 && profitMargin(a) > 0
+	}, func(a database.ItemAnalytics) float64 {
+		return profitMargin(a) * float64(buyLimit(a))
+	}), series, false)
 }
 
 // getFlipsByConsistency returns items with consistent but smaller margins
-func getFlipsByConsistency() []map[string]interface{} {
-	rows, err := database.DB.Query(`
-		SELECT ia.item_id, ia.sma5_buy, ia.sma5_sell, (ia.sma5_sell - ia.sma5_buy) as profit_margin
-		FROM item_analytics ia
-		WHERE ia.sma5_buy > 0 AND ia.sma5_sell > 0
-		AND (ia.sma5_sell - ia.sma5_buy) BETWEEN 100 AND 50000
-		AND ia.sma5_buy < 500000
-		ORDER BY (ia.sma5_sell - ia.sma5_buy) DESC
-		LIMIT 10
-	`)
-
-	if err != nil {
-		return []map[string]interface{}{}
-	}
-	defer rows.Close()
+func getFlipsByConsistency(store database.Store, analytics []database.ItemAnalytics, series string) []map[string]interface{} {
+	return flipItems(store, topFlips(analytics, func(a database.ItemAnalytics) bool {
+		margin := profitMargin(a)
+		return margin >= 100 && margin <= 50000 && a.SMA5Buy < 500000
+	}, profitMargin), series, false)
+}
 
-	return processFlipRows(rows)
+// profitMargin is the gap between an item's SMA5 sell and buy prices
+func profitMargin(a database.ItemAnalytics) float64 {
+	return a.SMA5Sell - a.SMA5Buy
 }
 
-// processFlipRows processes SQL rows into flip data with item names
-func processFlipRows(rows *sql.Rows) []map[string]interface{} {
-	var flips []map[string]interface{}
+// marginPercentage is an item's profit margin as a percentage of its buy price
+func marginPercentage(a database.ItemAnalytics) float64 {
+	return profitMargin(a) / a.SMA5Buy * 100
+}
 
-	for rows.Next() {
-		var itemID int
-		var smaBuy, smaSell, profitMargin float64
-		if err := rows.Scan(&itemID, &smaBuy, &smaSell, &profitMargin); err != nil {
-			continue
+// topFlips returns up to flipLimit items with both SMA5 prices known that pass
+// keep, highest score first
+func topFlips(analytics []database.ItemAnalytics, keep func(database.ItemAnalytics) bool, score func(database.ItemAnalytics) float64) []database.ItemAnalytics {
+	var flips []database.ItemAnalytics
+	for _, a := range analytics {
+		if a.SMA5Buy > 0 && a.SMA5Sell > 0 && keep(a) {
+			flips = append(flips, a)
 		}
-		itemName := database.GetItemName(itemID)
-		flips = append(flips, map[string]interface{}{
-			"item_id":     itemID,
-			"item_name":   itemName,
-			"sma5_buy":    smaBuy,
-			"sma5_sell":   smaSell,
-			"profit":      profitMargin,
-			"roi_percent": (profitMargin / smaBuy) * 100,
-		})
+	}
+	sort.SliceStable(flips, func(i, j int) bool {
+		return score(flips[i]) > score(flips[j])
+	})
+	if len(flips) > flipLimit {
+		flips = flips[:flipLimit]
 	}
 	return flips
 }
 
-// processFlipRowsWithPercentage processes SQL rows including margin percentage
-func processFlipRowsWithPercentage(rows *sql.Rows) []map[string]interface{} {
+// flipItems turns analytics into flip data with item names and the series'
+// indicators, optionally including the margin percentage
+func flipItems(store database.Store, analytics []database.ItemAnalytics, series string, withPercentage bool) []map[string]interface{} {
 	var flips []map[string]interface{}
-
-	for rows.Next() {
-		var itemID int
-		var smaBuy, smaSell, profitMargin, marginPercentage float64
-		if err := rows.Scan(&itemID, &smaBuy, &smaSell, &profitMargin, &marginPercentage); err != nil {
-			continue
+	for _, a := range analytics {
+		flip := map[string]interface{}{
+			"item_id":     a.ItemID,
+			"item_name":   store.ItemName(a.ItemID),
+			"sma5_buy":    a.SMA5Buy,
+			"sma5_sell":   a.SMA5Sell,
+			"profit":      profitMargin(a),
+			"roi_percent": (profitMargin(a) / a.SMA5Buy) * 100,
+		}
+		if withPercentage {
+			flip["margin_percentage"] = marginPercentage(a)
 		}
-		itemName := database.GetItemName(itemID)
-		flips = append(flips, map[string]interface{}{
-			"item_id":           itemID,
-			"item_name":         itemName,
-			"sma5_buy":          smaBuy,
-			"sma5_sell":         smaSell,
-			"profit":            profitMargin,
-			"margin_percentage": marginPercentage,
-			"roi_percent":       (profitMargin / smaBuy) * 100,
-		})
+		addIndicators(flip, a, series)
+		flips = append(flips, flip)
 	}
 	return flips
 }
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetCategorizedFlips(t *testing.T) {
+	code, body := get(t, "/categorized-flips", GetCategorizedFlips(flipStore()), "/categorized-flips?series=mid")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %v", code, body)
+	}
+	if body["series"] != "mid" || body["mode"] != "osrs" {
+		t.Errorf("series = %v, mode = %v", body["series"], body["mode"])
+	}
+
+	want := map[string][]float64{
+		"High Value Items":      {4151},
+		"Mid Value Items":       nil,
+		"Budget Items":          {2, 560},
+		"High Margin Items":     {2},
+		"High Volume Potential": {2, 560},
+		"Quick Flips":           nil,
+	}
+	categories := body["categories"].([]interface{})
+	if len(categories) != len(want) {
+		t.Fatalf("got %d categories, want %d", len(categories), len(want))
+	}
+	for _, c := range categories {
+		category := c.(map[string]interface{})
+		name := category["name"].(string)
+		ids := flipIDs(category["items"])
+		if len(ids) != len(want[name]) || category["count"] != float64(len(ids)) {
+			t.Errorf("%s: got items %v (count %v), want %v", name, ids, category["count"], want[name])
+			continue
+		}
+		for i := range ids {
+			if ids[i] != want[name][i] {
+				t.Errorf("%s: got items %v, want %v", name, ids, want[name])
+				break
+			}
+		}
+		if name == "High Margin Items" {
+			flip := category["items"].([]interface{})[0].(map[string]interface{})
+			if flip["item_name"] != "Cannonball" || flip["margin_percentage"] == nil {
+				t.Errorf("%s: unexpected flip %v", name, flip)
+			}
+		}
+	}
+}
+
+func TestGetCategorizedFlipsAsOf(t *testing.T) {
+	code, body := get(t, "/categorized-flips", GetCategorizedFlips(flipStore()), "/categorized-flips?as_of=1773496800")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %v", code, body)
+	}
+	if body["as_of"] != "2026-03-14T14:00:00Z" || body["timestamp"] != 1773496800.0 {
+		t.Errorf("as_of = %v, timestamp = %v", body["as_of"], body["timestamp"])
+	}
+	for _, c := range body["categories"].([]interface{}) {
+		category := c.(map[string]interface{})
+		if category["name"] == "Budget Items" {
+			if ids := flipIDs(category["items"]); len(ids) != 1 || ids[0] != 2 {
+				t.Errorf("Budget Items as of 14:00: got items %v, want only 2", ids)
+			}
+		}
+	}
+}
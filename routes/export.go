@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"errors"
+	"flipAssistant/bulk"
+	"flipAssistant/database"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportData streams a dataset (prices, analytics, candles_1h or candles_1d)
+// as a file download. Use ?format=csv|jsonl|parquet (default csv), ?items= for
+// a comma-separated item list, ?since= and ?until= (YYYY-MM-DD or RFC 3339)
+// for a time range and ?mode= to pick the market.
+func ExportData(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dataset := c.Param("dataset")
+		if err := bulk.CheckDataset(dataset); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		format := c.DefaultQuery("format", "csv")
+		if err := bulk.CheckFormat(format); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		mode, ok := gameMode(c)
+		if !ok {
+			return
+		}
+		filter, err := bulk.ParseFilter(mode, c.Query("items"), c.Query("since"), c.Query("until"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Rows are streamed as they are read, so a failure part-way through
+		// can only be logged and the download cut short
+		c.Header("Content-Type", bulk.ContentType(format))
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, dataset, mode, format))
+		c.Status(http.StatusOK)
+		if n, err := bulk.Export(c.Request.Context(), store, c.Writer, dataset, format, filter); err != nil {
+			log.Printf("Export of %s failed after %d rows: %v", dataset, n, err)
+		}
+	}
+}
+
+// ImportData merges an uploaded dataset file, sent as the request body, into
+// the database. Use ?format=csv|jsonl|parquet (default csv). Rows that are
+// already stored are skipped, so the same file can be sent twice. Bodies larger
+// than maxSize bytes are refused with 413.
+func ImportData(maxSize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dataset := c.Param("dataset")
+		if err := bulk.CheckDataset(dataset); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		format := c.DefaultQuery("format", "csv")
+		if err := bulk.CheckFormat(format); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Batches merged before a failure stay merged; sending the file again
+		// skips them
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+		result, err := bulk.Import(c.Request.Context(), body, dataset, format)
+		if err != nil {
+			log.Printf("Import of %s failed: %v", dataset, err)
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.JSON(status, gin.H{"error": err.Error(), "read": result.Read, "imported": result.Imported})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"dataset": dataset, "read": result.Read, "imported": result.Imported})
+	}
+}
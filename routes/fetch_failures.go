@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFetchFailures returns the most recent upstream requests that failed after
+// all retries, newest first. Use ?limit= to change how many are returned.
+func GetFetchFailures(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		failures, err := store.FetchFailures(c.Request.Context(), limit)
+		if err != nil {
+			log.Printf("Query error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"failures": failures, "count": len(failures)})
+	}
+}
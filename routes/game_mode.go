@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gameMode reads the ?mode= query parameter selecting which game mode's market
+// to read, defaulting to the main game. An unknown mode is answered with 400
+// and ok is false.
+func gameMode(c *gin.Context) (mode string, ok bool) {
+	mode = c.DefaultQuery("mode", database.DefaultGameMode)
+	if !database.GameModes[mode] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode, expected osrs, dmm or fsw"})
+		return "", false
+	}
+	return mode, true
+}
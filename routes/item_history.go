@@ -1,78 +1,213 @@
 package routes
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"time"
 
 	"flipAssistant/database"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetItemHistory(c *gin.Context) {
-	itemID := c.Param("id")
-	rows, err := database.DB.Query(`
-	SELECT timestamp, buy_price, sell_price
-	FROM item_prices
-	WHERE item_id = ?
-	ORDER BY timestamp ASC
-`, itemID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
-	}
-	defer rows.Close()
+// GetItemHistory returns an item's price history with RSI and MACD. The
+// optional resolution query parameter selects the series: "raw" (default) for
+// the stored /latest quotes, preceded by the 1h and 1d candles that older
+// quotes were rolled into, or "5m", "1h" and "24h" for the averaged buckets.
+// The optional mode query parameter selects the game mode's market, and series
+// the price the RSI and MACD follow: "buy" (default), "sell", "mid" or
+// "spread".
+func GetItemHistory(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		itemID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+			return
+		}
+		resolution := c.DefaultQuery("resolution", "raw")
+		mode, ok := gameMode(c)
+		if !ok {
+			return
+		}
+		series, ok := indicatorSeries(c)
+		if !ok {
+			return
+		}
+
+		var timestamps []time.Time
+		var buyPrices, sellPrices []float64
+		var points []seriesPoint
+
+		if resolution == "raw" {
+			timestamps, buyPrices, sellPrices, points, err = getStitchedPriceSeries(ctx, store, mode, itemID)
+		} else if _, ok := database.AverageTables[resolution]; ok {
+			timestamps, buyPrices, sellPrices, err = getAveragePriceSeries(ctx, store, mode, resolution, itemID)
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid resolution, expected raw, 5m, 1h or 24h"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
 
-	var timestamps []string
-	var buyPrices []float64
-	var sellPrices []float64
+		prices := make([]float64, len(buyPrices))
+		for i := range prices {
+			prices[i], _ = database.SeriesPrice(series, buyPrices[i], sellPrices[i])
+		}
+		rsi := database.CalculateRSIFromHistory(prices, database.RSIPeriod)
+		macdLine, macdSignal, macdHist := database.CalculateMACD(prices, database.MACDFast, database.MACDSlow, database.MACDSignal)
+
+		history := make([]map[string]interface{}, 0)
+		for i := 0; i < len(timestamps); i++ {
+			// Safety check for array bounds, though they should match
+			r := 0.0
+			if i < len(rsi) {
+				r = rsi[i]
+			}
+			ml, ms, mh := 0.0, 0.0, 0.0
+			if i < len(macdLine) {
+				ml = macdLine[i]
+				ms = macdSignal[i]
+				mh = macdHist[i]
+			}
 
-	for rows.Next() {
-		var timestamp string
-		var buy, sell int
-		if err := rows.Scan(&timestamp, &buy, &sell); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse data"})
+			point := map[string]interface{}{
+				"timestamp":   timestamps[i],
+				"buy_price":   int(buyPrices[i]),
+				"sell_price":  int(sellPrices[i]),
+				"rsi":         r,
+				"macd_line":   ml,
+				"macd_signal": ms,
+				"macd_hist":   mh,
+			}
+			if i < len(points) {
+				point["source"] = points[i].source
+				if candle := points[i].candle; candle != nil {
+					point["buy_open"], point["buy_high"], point["buy_low"] = candle.BuyOpen, candle.BuyHigh, candle.BuyLow
+					point["sell_open"], point["sell_high"], point["sell_low"] = candle.SellOpen, candle.SellHigh, candle.SellLow
+					point["ticks"] = candle.Ticks
+				}
+			}
+			history = append(history, point)
+		}
+
+		// Reverse history for returning newest first if that's what frontend expects,
+		// BUT re-reading original code: "ORDER BY timestamp DESC" was used.
+		// We fetched ASC for calculation. So we must REVERSE the final slice.
+		for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+			history[i], history[j] = history[j], history[i]
+		}
+
+		// Raw quotes carry no volume, so pair them with the 5-minute buckets
+		volumeResolution := "5m"
+		if resolution != "raw" {
+			volumeResolution = resolution
+		}
+		volumes, err := getAverageHistory(ctx, store, mode, volumeResolution, itemID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 			return
 		}
-		timestamps = append(timestamps, timestamp)
-		buyPrices = append(buyPrices, float64(buy))
-		sellPrices = append(sellPrices, float64(sell))
+
+		c.JSON(http.StatusOK, gin.H{"history": history, "volumes": volumes, "resolution": resolution, "mode": mode, "series": series})
 	}
+}
+
+// seriesPoint records where a point of a stitched series came from: "raw" for
+// a stored quote, or the resolution of the candle it closes
+type seriesPoint struct {
+	source string
+	candle *database.Candle
+}
 
-	// Calculate indicators based on Buy Price (could offer Sell Price too)
-	rsi := database.CalculateRSIFromHistory(buyPrices, 14)
-	macdLine, macdSignal, macdHist := database.CalculateMACD(buyPrices, 12, 26, 9)
+// getStitchedPriceSeries returns an item's full price history, oldest first:
+// 1d candles that end by the first 1h candle, 1h candles that end by the first
+// raw quote, then the raw quotes. Candles contribute their closing prices.
+func getStitchedPriceSeries(ctx context.Context, store database.Store, mode string, itemID int) ([]time.Time, []float64, []float64, []seriesPoint, error) {
+	quotes, err := store.PriceHistory(ctx, mode, itemID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
 
-	history := make([]map[string]interface{}, 0)
-	for i := 0; i < len(timestamps); i++ {
-		// Safety check for array bounds, though they should match
-		r := 0.0
-		if i < len(rsi) {
-			r = rsi[i]
+	var timestamps []time.Time
+	var buyPrices, sellPrices []float64
+	var points []seriesPoint
+	for _, q := range quotes {
+		timestamps = append(timestamps, q.Timestamp)
+		buyPrices = append(buyPrices, float64(q.BuyPrice))
+		sellPrices = append(sellPrices, float64(q.SellPrice))
+		points = append(points, seriesPoint{source: "raw"})
+	}
+
+	// Walk back from the raw quotes through ever coarser candles
+	for _, resolution := range database.CandleResolutions {
+		candles, err := store.Candles(ctx, mode, resolution.Name, itemID)
+		if err != nil {
+			return nil, nil, nil, nil, err
 		}
-		ml, ms, mh := 0.0, 0.0, 0.0
-		if i < len(macdLine) {
-			ml = macdLine[i]
-			ms = macdSignal[i]
-			mh = macdHist[i]
+		n := 0
+		for n < len(candles) && (len(timestamps) == 0 || !candles[n].Timestamp.Add(resolution.Size).After(timestamps[0])) {
+			n++
 		}
 
-		history = append(history, map[string]interface{}{
-			"timestamp":   timestamps[i],
-			"buy_price":   int(buyPrices[i]),
-			"sell_price":  int(sellPrices[i]),
-			"rsi":         r,
-			"macd_line":   ml,
-			"macd_signal": ms,
-			"macd_hist":   mh,
-		})
+		timestamps = append(make([]time.Time, n), timestamps...)
+		buyPrices = append(make([]float64, n), buyPrices...)
+		sellPrices = append(make([]float64, n), sellPrices...)
+		points = append(make([]seriesPoint, n), points...)
+		for i := 0; i < n; i++ {
+			c := &candles[i]
+			timestamps[i] = c.Timestamp
+			buyPrices[i] = float64(c.BuyClose)
+			sellPrices[i] = float64(c.SellClose)
+			points[i] = seriesPoint{source: resolution.Name, candle: c}
+		}
+	}
+	return timestamps, buyPrices, sellPrices, points, nil
+}
+
+// getAveragePriceSeries returns the averaged buckets for an item as a buy/sell
+// series, oldest first. Buckets where either side did not trade are skipped so
+// they don't drag the indicators towards zero.
+func getAveragePriceSeries(ctx context.Context, store database.Store, mode, resolution string, itemID int) ([]time.Time, []float64, []float64, error) {
+	averages, err := store.Averages(ctx, mode, resolution, itemID)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	// Reverse history for returning newest first if that's what frontend expects,
-	// BUT re-reading original code: "ORDER BY timestamp DESC" was used.
-	// We fetched ASC for calculation. So we must REVERSE the final slice.
-	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
-		history[i], history[j] = history[j], history[i]
+	var timestamps []time.Time
+	var buyPrices, sellPrices []float64
+	for _, a := range averages {
+		if a.AvgLowPrice <= 0 || a.AvgHighPrice <= 0 {
+			continue
+		}
+		timestamps = append(timestamps, a.Timestamp)
+		buyPrices = append(buyPrices, float64(a.AvgLowPrice))
+		sellPrices = append(sellPrices, float64(a.AvgHighPrice))
 	}
+	return timestamps, buyPrices, sellPrices, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"history": history})
+// getAverageHistory returns the averaged price buckets with traded volumes for
+// an item at the given resolution, newest first
+func getAverageHistory(ctx context.Context, store database.Store, mode, resolution string, itemID int) ([]map[string]interface{}, error) {
+	averages, err := store.Averages(ctx, mode, resolution, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]map[string]interface{}, 0, len(averages))
+	for i := len(averages) - 1; i >= 0; i-- {
+		a := averages[i]
+		history = append(history, map[string]interface{}{
+			"timestamp":         a.Timestamp,
+			"avg_high_price":    a.AvgHighPrice,
+			"avg_low_price":     a.AvgLowPrice,
+			"high_price_volume": a.HighPriceVolume,
+			"low_price_volume":  a.LowPriceVolume,
+		})
+	}
+	return history, nil
 }
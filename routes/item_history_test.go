@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// historyStore adds candles that older quotes of item 2 were rolled into, and
+// hourly averages, to flipStore's six raw quotes from 12:00 on 2026-03-14
+func historyStore() *database.MemoryStore {
+	store := flipStore()
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	store.AddCandles("osrs", "1d", 2,
+		database.Candle{Timestamp: day.AddDate(0, 0, -1), BuyOpen: 90, BuyHigh: 97, BuyLow: 88, BuyClose: 95,
+			SellOpen: 100, SellHigh: 107, SellLow: 98, SellClose: 105, Ticks: 24},
+		// Overlaps the hourly candles, so it is left out
+		database.Candle{Timestamp: day, BuyClose: 1, SellClose: 1, Ticks: 2},
+	)
+	store.AddCandles("osrs", "1h", 2,
+		database.Candle{Timestamp: day.Add(10 * time.Hour), BuyClose: 98, SellClose: 108, Ticks: 12},
+		database.Candle{Timestamp: day.Add(11 * time.Hour), BuyClose: 99, SellClose: 109, Ticks: 12},
+	)
+	store.AddAverages("osrs", "1h", 2,
+		database.Average{Timestamp: day.Add(10 * time.Hour), AvgHighPrice: 108, AvgLowPrice: 98, HighPriceVolume: 5, LowPriceVolume: 6},
+		// The buy side did not trade, so the bucket is left out of the prices
+		database.Average{Timestamp: day.Add(11 * time.Hour), AvgHighPrice: 109, HighPriceVolume: 3},
+	)
+	return store
+}
+
+func TestGetItemHistory(t *testing.T) {
+	handler := GetItemHistory(historyStore())
+
+	code, body := get(t, "/item-history/:id", handler, "/item-history/2")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %v", code, body)
+	}
+	if body["resolution"] != "raw" || body["series"] != "buy" || body["mode"] != "osrs" {
+		t.Errorf("resolution = %v, series = %v, mode = %v", body["resolution"], body["series"], body["mode"])
+	}
+
+	// Newest first: six raw quotes, two 1h candles, then the 1d candle
+	history := body["history"].([]interface{})
+	wantSources := []string{"raw", "raw", "raw", "raw", "raw", "raw", "1h", "1h", "1d"}
+	if len(history) != len(wantSources) {
+		t.Fatalf("got %d points, want %d: %v", len(history), len(wantSources), history)
+	}
+	for i, p := range history {
+		if source := p.(map[string]interface{})["source"]; source != wantSources[i] {
+			t.Errorf("point %d: source = %v, want %s", i, source, wantSources[i])
+		}
+	}
+	newest := history[0].(map[string]interface{})
+	if newest["buy_price"] != 105.0 || newest["sell_price"] != 115.0 || newest["ticks"] != nil {
+		t.Errorf("unexpected newest point %v", newest)
+	}
+	oldest := history[len(history)-1].(map[string]interface{})
+	if oldest["buy_price"] != 95.0 || oldest["buy_high"] != 97.0 || oldest["sell_low"] != 98.0 || oldest["ticks"] != 24.0 {
+		t.Errorf("unexpected 1d candle point %v", oldest)
+	}
+	if volumes := body["volumes"].([]interface{}); len(volumes) != 1 {
+		t.Errorf("got %d 5m volume buckets, want 1", len(volumes))
+	}
+
+	_, body = get(t, "/item-history/:id", handler, "/item-history/2?series=spread")
+	if body["series"] != "spread" {
+		t.Errorf("series = %v, want spread", body["series"])
+	}
+}
+
+func TestGetItemHistoryAverages(t *testing.T) {
+	code, body := get(t, "/item-history/:id", GetItemHistory(historyStore()), "/item-history/2?resolution=1h")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %v", code, body)
+	}
+	history := body["history"].([]interface{})
+	if len(history) != 1 {
+		t.Fatalf("got %d points, want 1: %v", len(history), history)
+	}
+	point := history[0].(map[string]interface{})
+	if point["buy_price"] != 98.0 || point["sell_price"] != 108.0 || point["source"] != nil {
+		t.Errorf("unexpected point %v", point)
+	}
+	// Volumes keep the bucket that has no buy price, newest first
+	volumes := body["volumes"].([]interface{})
+	if len(volumes) != 2 || volumes[0].(map[string]interface{})["high_price_volume"] != 3.0 {
+		t.Errorf("unexpected volumes %v", volumes)
+	}
+}
+
+func TestGetItemHistoryBadRequests(t *testing.T) {
+	handler := GetItemHistory(historyStore())
+	for _, url := range []string{
+		"/item-history/cannonball",
+		"/item-history/2?resolution=1w",
+		"/item-history/2?mode=bogus",
+		"/item-history/2?series=volume",
+	} {
+		if code, body := get(t, "/item-history/:id", handler, url); code != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want 400: %v", url, code, body)
+		}
+	}
+}
@@ -1,62 +1,104 @@
 package routes
 
 import (
+	"context"
 	"flipAssistant/database"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetItemInfo(c *gin.Context) {
-	itemIDStr := c.Param("id")
-	itemID, err := strconv.Atoi(itemIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
-		return
-	}
+// GetItemInfo returns an item's catalog entry
+func GetItemInfo(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		itemIDStr := c.Param("id")
+		itemID, err := strconv.Atoi(itemIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+			return
+		}
 
-	itemName := database.GetItemName(itemID)
+		item, exists := store.Item(itemID)
+		if !exists {
+			c.JSON(http.StatusOK, gin.H{
+				"id":   itemID,
+				"name": store.ItemName(itemID),
+			})
+			return
+		}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":   itemID,
-		"name": itemName,
-	})
+		c.JSON(http.StatusOK, gin.H{
+			"id":        itemID,
+			"name":      item.Name,
+			"examine":   item.Examine,
+			"members":   item.Members,
+			"buy_limit": item.Limit,
+			"highalch":  item.HighAlch,
+			"lowalch":   item.LowAlch,
+			"value":     item.Value,
+			"icon":      item.Icon,
+		})
+	}
 }
 
-func GetAllTrackedItems(c *gin.Context) {
-	popularItems := database.GetPopularItems()
-	var items []map[string]interface{}
+// GetAllTrackedItems lists the ID and name of every tracked item
+func GetAllTrackedItems(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		popularItems := store.TrackedItems()
+		var items []map[string]interface{}
 
-	for _, itemID := range popularItems {
-		items = append(items, map[string]interface{}{
-			"id":   itemID,
-			"name": database.GetItemName(itemID),
+		for _, itemID := range popularItems {
+			items = append(items, map[string]interface{}{
+				"id":   itemID,
+				"name": store.ItemName(itemID),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"items": items,
+			"count": len(items),
 		})
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"items": items,
-		"count": len(items),
-	})
 }
 
 // SearchItemByName searches for an item by name and returns its ID
-func SearchItemByName(c *gin.Context) {
-	itemName := c.Query("name")
-	if itemName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Item name is required"})
-		return
-	}
+func SearchItemByName(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		itemName := c.Query("name")
+		if itemName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Item name is required"})
+			return
+		}
 
-	itemID := database.GetItemIDByName(itemName)
-	if itemID == -1 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
-		return
+		itemID := store.ItemIDByName(itemName)
+		if itemID == -1 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"id":   itemID,
+			"name": store.ItemName(itemID),
+		})
 	}
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":   itemID,
-		"name": database.GetItemName(itemID),
-	})
+// ReloadItemCatalog reloads the item catalog with reload and reports what it
+// now holds. Lookups keep being served from the previous catalog until the new
+// one is swapped in, and a failed reload leaves it in place.
+func ReloadItemCatalog(catalog *database.ItemCatalog, reload func(context.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := reload(c.Request.Context()); err != nil {
+			log.Printf("Item catalog reload error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Item catalog reload failed: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"items":     catalog.Len(),
+			"loaded_at": catalog.LoadedAt().UTC().Format(time.RFC3339),
+		})
+	}
 }
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"net/http"
+	"testing"
+)
+
+func TestSearchItemByName(t *testing.T) {
+	store := database.NewMemoryStore()
+	store.SetItems(
+		database.Item{ID: 2, Name: "Cannonball"},
+		database.Item{ID: 560, Name: "Death rune"},
+		database.Item{ID: 4151, Name: "Abyssal whip"},
+		// A variant with the same name in different case loses to the exact match
+		database.Item{ID: 4178, Name: "abyssal whip"},
+	)
+	handler := SearchItemByName(store)
+
+	for url, want := range map[string]float64{
+		"/search-item?name=death%20rune":   560,
+		"/search-item?name=Abyssal%20whip": 4151,
+		"/search-item?name=abyssal%20whip": 4178,
+	} {
+		code, body := get(t, "/search-item", handler, url)
+		if code != http.StatusOK || body["id"] != want {
+			t.Errorf("GET %s: status = %d, id = %v, want %v", url, code, body["id"], want)
+		}
+	}
+
+	if code, body := get(t, "/search-item", handler, "/search-item?name=Dragon%20claws"); code != http.StatusNotFound {
+		t.Errorf("unknown item: status = %d, want 404: %v", code, body)
+	}
+	if code, body := get(t, "/search-item", handler, "/search-item"); code != http.StatusBadRequest {
+		t.Errorf("missing name: status = %d, want 400: %v", code, body)
+	}
+}
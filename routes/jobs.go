@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"errors"
+	"flipAssistant/database"
+	"flipAssistant/scheduler"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJobs returns the status of every scheduled job
+func GetJobs(s *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobs := s.Status()
+		c.JSON(http.StatusOK, gin.H{"jobs": jobs, "count": len(jobs)})
+	}
+}
+
+// TriggerJob runs a scheduled job now instead of waiting for its interval.
+// Unknown jobs are 404; jobs that have finished or are running are 409.
+func TriggerJob(s *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if err := s.Trigger(name); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, scheduler.ErrUnknownJob) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"job": name, "status": "triggered"})
+	}
+}
+
+// GetJobRuns returns the recorded run history of a job, newest first.
+// Use ?limit= to change how many runs are returned.
+func GetJobRuns(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		runs, err := store.JobRuns(c.Request.Context(), c.Param("name"), limit)
+		if err != nil {
+			log.Printf("Query error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"runs": runs, "count": len(runs)})
+	}
+}
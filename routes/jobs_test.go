@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"context"
+	"flipAssistant/database"
+	"flipAssistant/scheduler"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTriggerJob(t *testing.T) {
+	// Finished runs are recorded in job_runs
+	database.InitDB(database.Options{DSN: filepath.Join(t.TempDir(), "jobs.db"), BusyTimeout: 5 * time.Second})
+	t.Cleanup(database.Close)
+
+	release := make(chan struct{})
+	s := scheduler.New()
+	s.Register(scheduler.Job{Name: "idle", Interval: time.Hour, Run: func(context.Context) error { return nil }})
+	s.Register(scheduler.Job{Name: "replay", Interval: time.Hour, RunOnStart: true, Run: func(context.Context) error {
+		return scheduler.ErrDone
+	}})
+	s.Register(scheduler.Job{Name: "busy", Interval: time.Hour, RunOnStart: true, Run: func(context.Context) error {
+		<-release
+		return nil
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	t.Cleanup(func() {
+		close(release)
+		cancel()
+		s.Wait()
+	})
+
+	// Wait until the replay has finished and the busy job is running
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		var done, running bool
+		for _, st := range s.Status() {
+			done = done || st.Name == "replay" && st.Done
+			running = running || st.Name == "busy" && st.Running
+		}
+		if done && running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the jobs to start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	r := gin.New()
+	r.POST("/jobs/:name/run", TriggerJob(s))
+	for name, want := range map[string]int{
+		"idle":    http.StatusAccepted,
+		"missing": http.StatusNotFound,
+		"replay":  http.StatusConflict,
+		"busy":    http.StatusConflict,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/jobs/"+name+"/run", nil))
+		if w.Code != want {
+			t.Errorf("POST /jobs/%s/run: status = %d, want %d (%s)", name, w.Code, want, w.Body.String())
+		}
+	}
+}
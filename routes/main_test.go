@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// get serves a GET request for url through handler mounted at route and
+// returns the status code and decoded JSON body
+func get(t *testing.T, route string, handler gin.HandlerFunc, url string) (int, map[string]interface{}) {
+	t.Helper()
+	r := gin.New()
+	r.GET(route, handler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("GET %s: invalid JSON response %q: %v", url, w.Body.String(), err)
+	}
+	return w.Code, body
+}
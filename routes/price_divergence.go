@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPriceDivergence returns a handler listing items whose wiki price has
+// drifted from the official guide price by more than a threshold, largest gap
+// first. The wiki price is the midpoint of the SMA5 buy and sell prices and
+// divergence is relative to the most recent guide price, so 0.15 flags a 15%
+// gap. Use ?threshold= to override the configured default and ?limit= to change
+// how many items are returned. Guide prices only exist for the main game, so
+// any other ?mode= is rejected.
+func GetPriceDivergence(store database.Store, defaultThreshold float64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mode, ok := gameMode(c)
+		if !ok {
+			return
+		}
+		if mode != database.DefaultGameMode {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Guide prices only exist for the main game, expected mode osrs"})
+			return
+		}
+		threshold := defaultThreshold
+		if v := c.Query("threshold"); v != "" {
+			t, err := strconv.ParseFloat(v, 64)
+			if err != nil || t < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid threshold"})
+				return
+			}
+			threshold = t
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		analytics, err := store.Analytics(ctx, mode)
+		if err != nil {
+			log.Printf("Query error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			return
+		}
+		guidePrices, err := store.LatestGuidePrices(ctx)
+		if err != nil {
+			log.Printf("Query error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			return
+		}
+
+		type divergence struct {
+			ItemID     int     `json:"item_id"`
+			ItemName   string  `json:"item_name"`
+			WikiPrice  float64 `json:"wiki_price"`
+			GuidePrice int     `json:"guide_price"`
+			GuideDate  string  `json:"guide_date"`
+			Divergence float64 `json:"divergence"`
+		}
+
+		items := make([]divergence, 0)
+		for _, a := range analytics {
+			guide, ok := guidePrices[a.ItemID]
+			if !ok || a.SMA5Buy <= 0 || a.SMA5Sell <= 0 || guide.Price <= 0 {
+				continue
+			}
+			d := divergence{
+				ItemID:     a.ItemID,
+				WikiPrice:  (a.SMA5Buy + a.SMA5Sell) / 2,
+				GuidePrice: guide.Price,
+				GuideDate:  guide.Date.UTC().Format(time.RFC3339),
+			}
+			d.Divergence = (d.WikiPrice - float64(d.GuidePrice)) / float64(d.GuidePrice)
+			if math.Abs(d.Divergence) <= threshold {
+				continue
+			}
+			d.ItemName = store.ItemName(d.ItemID)
+			items = append(items, d)
+		}
+
+		sort.Slice(items, func(i, j int) bool {
+			return math.Abs(items[i].Divergence) > math.Abs(items[j].Divergence)
+		})
+		if len(items) > limit {
+			items = items[:limit]
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items), "threshold": threshold, "mode": mode})
+	}
+}
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func divergenceStore() *database.MemoryStore {
+	store := database.NewMemoryStore()
+	store.SetItems(database.Item{ID: 2, Name: "Cannonball"}, database.Item{ID: 560, Name: "Death rune"})
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	store.AddGuidePrices(2, database.GuidePrice{Date: day.AddDate(0, 0, -1), Price: 150}, database.GuidePrice{Date: day, Price: 100})
+	store.AddGuidePrices(560, database.GuidePrice{Date: day, Price: 200})
+	store.SetAnalytics(
+		// 30% above the latest guide price
+		database.ItemAnalytics{ItemID: 2, GameMode: "osrs", SMA5Buy: 120, SMA5Sell: 140},
+		// 5% below
+		database.ItemAnalytics{ItemID: 560, GameMode: "osrs", SMA5Buy: 185, SMA5Sell: 195},
+		// No guide price to compare with
+		database.ItemAnalytics{ItemID: 4151, GameMode: "osrs", SMA5Buy: 1000, SMA5Sell: 1100},
+	)
+	return store
+}
+
+func TestGetPriceDivergence(t *testing.T) {
+	handler := GetPriceDivergence(divergenceStore(), 0.15)
+
+	code, body := get(t, "/price-divergence", handler, "/price-divergence")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %v", code, body)
+	}
+	items := body["items"].([]interface{})
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1: %v", len(items), items)
+	}
+	item := items[0].(map[string]interface{})
+	if item["item_id"] != 2.0 || item["item_name"] != "Cannonball" || item["guide_price"] != 100.0 {
+		t.Errorf("unexpected item %v", item)
+	}
+	if d := item["divergence"].(float64); d < 0.2999 || d > 0.3001 {
+		t.Errorf("divergence = %v, want 0.3", d)
+	}
+
+	// A lower threshold also flags the 5% gap, largest first
+	_, body = get(t, "/price-divergence", handler, "/price-divergence?threshold=0.01")
+	items = body["items"].([]interface{})
+	if len(items) != 2 || items[1].(map[string]interface{})["item_id"] != 560.0 {
+		t.Errorf("threshold=0.01 returned %v", items)
+	}
+}
+
+func TestGetPriceDivergenceBadRequests(t *testing.T) {
+	handler := GetPriceDivergence(divergenceStore(), 0.15)
+	for _, url := range []string{
+		"/price-divergence?mode=dmm",
+		"/price-divergence?mode=bogus",
+		"/price-divergence?threshold=-1",
+		"/price-divergence?limit=0",
+	} {
+		if code, body := get(t, "/price-divergence", handler, url); code != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want 400: %v", url, code, body)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetQuarantine returns the most recent quotes rejected by the data-quality
+// gate in one game mode, newest first. Use ?item_id= to filter to one item,
+// ?limit= to change how many are returned and ?mode= to pick the market.
+func GetQuarantine(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mode, ok := gameMode(c)
+		if !ok {
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+
+		itemID := 0
+		if idStr := c.Query("item_id"); idStr != "" {
+			itemID, err = strconv.Atoi(idStr)
+			if err != nil || itemID <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+				return
+			}
+		}
+
+		quarantined, err := store.QuarantinedQuotes(c.Request.Context(), mode, itemID, limit)
+		if err != nil {
+			log.Printf("Query error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			return
+		}
+
+		quotes := make([]map[string]interface{}, 0, len(quarantined))
+		for _, q := range quarantined {
+			quotes = append(quotes, map[string]interface{}{
+				"item_id":        q.ItemID,
+				"item_name":      store.ItemName(q.ItemID),
+				"buy_price":      q.BuyPrice,
+				"sell_price":     q.SellPrice,
+				"buy_time":       q.BuyTime,
+				"sell_time":      q.SellTime,
+				"reason":         q.Reason,
+				"quarantined_at": q.QuarantinedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"quarantined": quotes, "count": len(quotes), "mode": mode})
+	}
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"net/http"
+	"testing"
+)
+
+func quarantineStore() *database.MemoryStore {
+	store := database.NewMemoryStore()
+	store.SetItems(database.Item{ID: 2, Name: "Cannonball"}, database.Item{ID: 560, Name: "Death rune"})
+	store.AddQuarantinedQuotes("osrs",
+		database.QuarantinedQuote{ItemID: 2, BuyPrice: 1, SellPrice: 200, Reason: "spike", QuarantinedAt: "2026-03-14 10:00:00"},
+		database.QuarantinedQuote{ItemID: 560, BuyPrice: 190, SellPrice: 195, Reason: "stale", QuarantinedAt: "2026-03-14 11:00:00"},
+		database.QuarantinedQuote{ItemID: 2, BuyPrice: 180, Reason: "never traded", QuarantinedAt: "2026-03-14 12:00:00"},
+	)
+	store.AddQuarantinedQuotes("dmm", database.QuarantinedQuote{ItemID: 2, Reason: "spike"})
+	return store
+}
+
+func TestGetQuarantine(t *testing.T) {
+	handler := GetQuarantine(quarantineStore())
+
+	code, body := get(t, "/quarantine", handler, "/quarantine")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %v", code, body)
+	}
+	quotes := body["quarantined"].([]interface{})
+	if len(quotes) != 3 || body["count"] != 3.0 {
+		t.Fatalf("got %d quotes (count %v), want 3", len(quotes), body["count"])
+	}
+	newest := quotes[0].(map[string]interface{})
+	if newest["reason"] != "never traded" || newest["item_name"] != "Cannonball" {
+		t.Errorf("unexpected newest quote %v", newest)
+	}
+
+	for url, want := range map[string]int{
+		"/quarantine?item_id=2":        2,
+		"/quarantine?limit=1":          1,
+		"/quarantine?item_id=560":      1,
+		"/quarantine?mode=dmm":         1,
+		"/quarantine?item_id=4151":     0,
+		"/quarantine?mode=fsw&limit=5": 0,
+	} {
+		if _, body := get(t, "/quarantine", handler, url); body["count"] != float64(want) {
+			t.Errorf("GET %s: count = %v, want %d", url, body["count"], want)
+		}
+	}
+}
+
+func TestGetQuarantineBadRequests(t *testing.T) {
+	handler := GetQuarantine(quarantineStore())
+	for _, url := range []string{
+		"/quarantine?mode=bogus",
+		"/quarantine?limit=0",
+		"/quarantine?item_id=cannonball",
+	} {
+		if code, body := get(t, "/quarantine", handler, url); code != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want 400: %v", url, code, body)
+		}
+	}
+}
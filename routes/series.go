@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// indicatorSeries reads the ?series= query parameter selecting which price the
+// RSI and MACD follow, defaulting to the buy price. An unknown series is
+// answered with 400 and ok is false.
+func indicatorSeries(c *gin.Context) (series string, ok bool) {
+	series = c.DefaultQuery("series", database.SeriesBuy)
+	if _, known := database.SeriesPrice(series, 0, 0); !known {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series, expected buy, sell, mid or spread"})
+		return "", false
+	}
+	return series, true
+}
+
+// addIndicators adds a series' stored RSI and MACD to a flip
+func addIndicators(flip map[string]interface{}, a database.ItemAnalytics, series string) {
+	ind := a.Series(series)
+	flip["rsi"] = ind.RSI
+	flip["macd_line"] = ind.MACDLine
+	flip["macd_signal"] = ind.MACDSignal
+	flip["macd_hist"] = ind.MACDHist
+}
@@ -4,42 +4,65 @@ import (
 	"flipAssistant/database"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SuggestFlips(c *gin.Context) {
-	rows, err := database.DB.Query(`
-        SELECT a.item_id, a.sma5_buy, a.sma5_sell, 
-               (a.sma5_sell - a.sma5_buy) AS profit_margin
-        FROM item_analytics a
-        WHERE a.sma5_buy > 0 AND a.sma5_sell > 0
-        AND a.sma5_buy < 200000000 -- Exclude items over 200M to avoid low volume 3rd age items
-        ORDER BY profit_margin DESC
-        LIMIT 10;
-    `)
-	if err != nil {
-		log.Printf("Query error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
-		return
-	}
-	defer rows.Close()
+// SuggestFlips returns the ten items with the widest SMA5 margin, with units
+// traded over the last hour and the RSI and MACD of the ?series= price. With
+// ?as_of= the ranking and volumes are those the stored data gave at that time.
+func SuggestFlips(store database.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		mode, ok := gameMode(c)
+		if !ok {
+			return
+		}
+		series, ok := indicatorSeries(c)
+		if !ok {
+			return
+		}
 
-	var flips []map[string]interface{}
-	for rows.Next() {
-		var itemID int
-		var smaBuy, smaSell, profitMargin float64
-		if err := rows.Scan(&itemID, &smaBuy, &smaSell, &profitMargin); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse data"})
+		analytics, asOf, approximate, ok := flipAnalytics(c, store, mode)
+		if !ok {
+			return
+		}
+		// Units actually traded over the last hour of 5-minute buckets
+		now := time.Now()
+		if !asOf.IsZero() {
+			now = asOf
+		}
+		volumes, err := store.RecentVolumes(ctx, mode, now.Add(-time.Hour), now)
+		if err != nil {
+			log.Printf("Query error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
 			return
 		}
-		flips = append(flips, map[string]interface{}{
-			"item_id":   itemID,
-			"sma5_buy":  smaBuy,
-			"sma5_sell": smaSell,
-			"profit":    profitMargin,
-		})
-	}
 
-	c.JSON(http.StatusOK, gin.H{"suggested_flips": flips})
+		top := topFlips(analytics, func(a database.ItemAnalytics) bool {
+			return a.SMA5Buy < 200000000 // Exclude items over 200M to avoid low volume 3rd age items
+		}, profitMargin)
+
+		var flips []map[string]interface{}
+		for _, a := range top {
+			flip := map[string]interface{}{
+				"item_id":        a.ItemID,
+				"sma5_buy":       a.SMA5Buy,
+				"sma5_sell":      a.SMA5Sell,
+				"profit":         profitMargin(a),
+				"high_volume_1h": volumes[a.ItemID].High,
+				"low_volume_1h":  volumes[a.ItemID].Low,
+			}
+			addIndicators(flip, a, series)
+			flips = append(flips, flip)
+		}
+
+		response := gin.H{"suggested_flips": flips, "mode": mode, "series": series}
+		if !asOf.IsZero() {
+			response["as_of"] = asOf.UTC().Format(time.RFC3339)
+			response["approximate"] = approximate
+		}
+		c.JSON(http.StatusOK, response)
+	}
 }
@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"flipAssistant/database"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flipStore holds three profitable items in the main game and one in DMM,
+// with a known quote history for item 2 to rank ?as_of= requests by
+func flipStore() *database.MemoryStore {
+	store := database.NewMemoryStore()
+	store.SetItems(
+		database.Item{ID: 2, Name: "Cannonball", Limit: 11000},
+		database.Item{ID: 560, Name: "Death rune", Limit: 25000},
+		database.Item{ID: 4151, Name: "Abyssal whip", Limit: 70},
+	)
+	store.SetAnalytics(
+		database.ItemAnalytics{ItemID: 2, GameMode: "osrs", SMA5Buy: 180, SMA5Sell: 200,
+			Buy: database.Indicators{RSI: 40}, Sell: database.Indicators{RSI: 60}},
+		database.ItemAnalytics{ItemID: 560, GameMode: "osrs", SMA5Buy: 190, SMA5Sell: 195},
+		database.ItemAnalytics{ItemID: 4151, GameMode: "osrs", SMA5Buy: 1500000, SMA5Sell: 1560000},
+		database.ItemAnalytics{ItemID: 2, GameMode: "dmm", SMA5Buy: 300, SMA5Sell: 400},
+	)
+	store.AddAverages("osrs", "5m", 2, database.Average{
+		Timestamp: time.Now().Add(-10 * time.Minute), HighPriceVolume: 50, LowPriceVolume: 70,
+	})
+
+	start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		store.AddPrices("osrs", 2, database.PricePoint{
+			Timestamp: start.Add(time.Duration(i) * time.Hour), BuyPrice: 100 + i, SellPrice: 110 + i,
+		})
+	}
+	return store
+}
+
+// flipIDs returns the item IDs of a decoded flip list, in order
+func flipIDs(flips interface{}) []float64 {
+	var ids []float64
+	list, _ := flips.([]interface{})
+	for _, f := range list {
+		ids = append(ids, f.(map[string]interface{})["item_id"].(float64))
+	}
+	return ids
+}
+
+func TestSuggestFlips(t *testing.T) {
+	handler := SuggestFlips(flipStore())
+
+	code, body := get(t, "/suggest-flips", handler, "/suggest-flips")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %v", code, body)
+	}
+	flips := body["suggested_flips"].([]interface{})
+	if ids := flipIDs(flips); len(ids) != 3 || ids[0] != 4151 || ids[1] != 2 || ids[2] != 560 {
+		t.Fatalf("got items %v, want 4151, 2, 560 by profit", ids)
+	}
+	cannonball := flips[1].(map[string]interface{})
+	if cannonball["profit"] != 20.0 || cannonball["high_volume_1h"] != 50.0 || cannonball["low_volume_1h"] != 70.0 {
+		t.Errorf("unexpected flip %v", cannonball)
+	}
+	if cannonball["rsi"] != 40.0 || body["series"] != "buy" || body["mode"] != "osrs" {
+		t.Errorf("default series: rsi = %v, series = %v, mode = %v", cannonball["rsi"], body["series"], body["mode"])
+	}
+
+	_, body = get(t, "/suggest-flips", handler, "/suggest-flips?series=sell")
+	if rsi := body["suggested_flips"].([]interface{})[1].(map[string]interface{})["rsi"]; rsi != 60.0 {
+		t.Errorf("series=sell: rsi = %v, want 60", rsi)
+	}
+
+	_, body = get(t, "/suggest-flips", handler, "/suggest-flips?mode=dmm")
+	if ids := flipIDs(body["suggested_flips"]); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("mode=dmm returned items %v, want only 2", ids)
+	}
+}
+
+func TestSuggestFlipsAsOf(t *testing.T) {
+	store := flipStore()
+	handler := SuggestFlips(store)
+
+	// The first three hourly quotes are stored by 14:00
+	code, body := get(t, "/suggest-flips", handler, "/suggest-flips?as_of=2026-03-14T14:00:00Z")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %v", code, body)
+	}
+	if body["as_of"] != "2026-03-14T14:00:00Z" {
+		t.Errorf("as_of = %v", body["as_of"])
+	}
+	flips := body["suggested_flips"].([]interface{})
+	if len(flips) != 1 {
+		t.Fatalf("got %d flips, want 1: %v", len(flips), flips)
+	}
+	flip := flips[0].(map[string]interface{})
+	if flip["item_id"] != 2.0 || flip["sma5_buy"] != 101.0 || flip["sma5_sell"] != 111.0 {
+		t.Errorf("unexpected flip %v", flip)
+	}
+
+	if body["approximate"] != false {
+		t.Errorf("approximate = %v without compacted history", body["approximate"])
+	}
+
+	// A much later as_of still folds in every quote, and once retention has
+	// rolled older quotes into candles the values are labelled approximate
+	store.AddCandles("osrs", "1d", 2, database.Candle{Timestamp: time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC)})
+	_, body = get(t, "/suggest-flips", handler, "/suggest-flips?as_of=2026-04-14T00:00:00Z")
+	flip = body["suggested_flips"].([]interface{})[0].(map[string]interface{})
+	if flip["sma5_buy"] != 103.0 {
+		t.Errorf("a month later: sma5_buy = %v, want 103 from the last five quotes", flip["sma5_buy"])
+	}
+	if body["approximate"] != true {
+		t.Errorf("approximate = %v with compacted history", body["approximate"])
+	}
+}
+
+func TestFlipBadRequests(t *testing.T) {
+	store := flipStore()
+	for _, tc := range []struct {
+		route   string
+		handler gin.HandlerFunc
+		query   string
+	}{
+		{"/suggest-flips", SuggestFlips(store), "mode=bogus"},
+		{"/suggest-flips", SuggestFlips(store), "series=volume"},
+		{"/suggest-flips", SuggestFlips(store), "as_of=yesterday"},
+		{"/suggest-flips", SuggestFlips(store), "as_of=-5"},
+		// Before the oldest stored quote
+		{"/suggest-flips", SuggestFlips(store), "as_of=2026-03-14T11:59:59Z"},
+		{"/categorized-flips", GetCategorizedFlips(store), "mode=bogus"},
+		{"/categorized-flips", GetCategorizedFlips(store), "series=volume"},
+		{"/categorized-flips", GetCategorizedFlips(store), "as_of=2026-13-01T00:00:00Z"},
+		{"/categorized-flips", GetCategorizedFlips(store), "as_of=2026-03-01T00:00:00Z"},
+	} {
+		url := tc.route + "?" + tc.query
+		if code, body := get(t, tc.route, tc.handler, url); code != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want 400: %v", url, code, body)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"flipAssistant/database"
+)
+
+// ErrDone is returned by a job, possibly wrapped, once it has no more work,
+// such as a replay that reached its last snapshot. The run is recorded as
+// successful and the job is not scheduled again.
+var ErrDone = errors.New("job done")
+
+// Errors returned by Trigger
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrRunning    = errors.New("job is already running")
+)
+
+// Job is a named task run periodically by the Scheduler
+type Job struct {
+	Name string
+	// Interval is the pause between the end of one run and the start of the next
+	Interval time.Duration
+	// Jitter adds a random extra delay in [0, Jitter) to each interval so jobs
+	// sharing an upstream don't fire in lockstep
+	Jitter time.Duration
+	// RunOnStart runs the job as soon as the scheduler starts instead of
+	// waiting for the first interval
+	RunOnStart bool
+	// Run performs the job; ctx is cancelled when the scheduler shuts down
+	Run func(ctx context.Context) error
+}
+
+// JobStatus describes the state of a registered job
+type JobStatus struct {
+	Name       string     `json:"name"`
+	Interval   string     `json:"interval"`
+	Running    bool       `json:"running"`
+	Done       bool       `json:"done"`
+	LastStart  *time.Time `json:"last_start,omitempty"`
+	LastEnd    *time.Time `json:"last_end,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	NextRun    *time.Time `json:"next_run,omitempty"`
+	RunCount   int        `json:"run_count"`
+	ErrorCount int        `json:"error_count"`
+}
+
+type jobState struct {
+	job     Job
+	status  JobStatus
+	trigger chan struct{}
+}
+
+// Scheduler runs registered jobs on their own intervals, one goroutine per
+// job, and records every run in the job_runs table
+type Scheduler struct {
+	mu    sync.Mutex
+	jobs  map[string]*jobState
+	order []string
+	wg    sync.WaitGroup
+}
+
+// New creates an empty scheduler
+func New() *Scheduler {
+	return &Scheduler{jobs: make(map[string]*jobState)}
+}
+
+// Register adds a job; it must be called before Start
+func (s *Scheduler) Register(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[job.Name]; exists {
+		log.Fatalf("Job %q registered twice", job.Name)
+	}
+	s.jobs[job.Name] = &jobState{
+		job:     job,
+		status:  JobStatus{Name: job.Name, Interval: job.Interval.String()},
+		trigger: make(chan struct{}, 1),
+	}
+	s.order = append(s.order, job.Name)
+}
+
+// Start launches every registered job. Cancelling ctx stops scheduling new
+// runs and cancels the ones in progress; use Wait to let them wind down.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range s.order {
+		s.wg.Add(1)
+		go s.loop(ctx, s.jobs[name])
+	}
+	log.Printf("Scheduler started with %d jobs", len(s.order))
+}
+
+// Wait blocks until every job loop has exited after ctx was cancelled
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Trigger asks a job to run now instead of waiting for its next interval. It
+// returns ErrUnknownJob for a name that isn't registered, ErrDone for a job
+// that has finished and ErrRunning for one that is running now.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	state, exists := s.jobs[name]
+	var status JobStatus
+	if exists {
+		status = state.status
+	}
+	s.mu.Unlock()
+
+	switch {
+	case !exists:
+		return fmt.Errorf("%w %q", ErrUnknownJob, name)
+	case status.Done:
+		return fmt.Errorf("job %q has finished: %w", name, ErrDone)
+	case status.Running:
+		return fmt.Errorf("job %q: %w", name, ErrRunning)
+	}
+
+	select {
+	case state.trigger <- struct{}{}:
+	default: // A run is already queued
+	}
+	return nil
+}
+
+// Status returns a snapshot of every job's state, in registration order
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.order))
+	for _, name := range s.order {
+		statuses = append(statuses, s.jobs[name].status)
+	}
+	return statuses
+}
+
+// loop runs a job until ctx is cancelled or the job is done
+func (s *Scheduler) loop(ctx context.Context, state *jobState) {
+	defer s.wg.Done()
+
+	if !state.job.RunOnStart && !s.wait(ctx, state) {
+		return
+	}
+	for {
+		if done := s.run(ctx, state); done {
+			return
+		}
+		if !s.wait(ctx, state) {
+			return
+		}
+	}
+}
+
+// wait sleeps for the job's interval plus jitter, or until it is triggered.
+// It returns false if ctx was cancelled instead.
+func (s *Scheduler) wait(ctx context.Context, state *jobState) bool {
+	delay := state.job.Interval
+	if state.job.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(state.job.Jitter)))
+	}
+
+	next := time.Now().Add(delay)
+	s.mu.Lock()
+	state.status.NextRun = &next
+	s.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-state.trigger:
+		log.Printf("Job %s triggered manually", state.job.Name)
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// run executes the job once, recording its outcome. It returns true if the
+// job reported ErrDone.
+func (s *Scheduler) run(ctx context.Context, state *jobState) bool {
+	start := time.Now()
+	s.mu.Lock()
+	state.status.Running = true
+	state.status.LastStart = &start
+	state.status.NextRun = nil
+	s.mu.Unlock()
+
+	err := safeRun(ctx, state.job.Run)
+	end := time.Now()
+
+	done := errors.Is(err, ErrDone)
+	if done {
+		log.Printf("Job %s finished: %v", state.job.Name, err)
+		err = nil
+	}
+
+	s.mu.Lock()
+	state.status.Running = false
+	state.status.Done = done
+	state.status.LastEnd = &end
+	state.status.RunCount++
+	state.status.LastError = ""
+	if err != nil {
+		state.status.LastError = err.Error()
+		state.status.ErrorCount++
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Job %s failed after %v: %v", state.job.Name, end.Sub(start), err)
+	}
+	// A run cut short by shutdown is still recorded
+	if recErr := database.RecordJobRun(context.WithoutCancel(ctx), state.job.Name, start, end, err); recErr != nil {
+		log.Printf("Error recording run of job %s: %v", state.job.Name, recErr)
+	}
+	return done
+}
+
+// safeRun calls fn, converting a panic into an error so one bad run doesn't
+// take the whole process down
+func safeRun(ctx context.Context, fn func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,232 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"flipAssistant/database"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// start runs s until the test ends
+func start(t *testing.T, s *Scheduler) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		s.Wait()
+	})
+	return cancel
+}
+
+// waitFor polls cond until it holds or a few seconds have passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// status returns the state of the named job
+func status(t *testing.T, s *Scheduler, name string) JobStatus {
+	t.Helper()
+	for _, st := range s.Status() {
+		if st.Name == name {
+			return st
+		}
+	}
+	t.Fatalf("job %q not registered", name)
+	return JobStatus{}
+}
+
+// uniqueName returns a job name not used by earlier runs of the test, whose
+// records are still in the database
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+}
+
+// counter returns a job function that counts its runs
+func counter(runs *atomic.Int32) func(context.Context) error {
+	return func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}
+}
+
+func TestIntervalAndJitter(t *testing.T) {
+	var runs atomic.Int32
+	s := New()
+	s.Register(Job{Name: "test-interval", Interval: 30 * time.Millisecond, Run: counter(&runs)})
+	s.Register(Job{Name: "test-jitter", Interval: time.Hour, Jitter: time.Hour, Run: counter(new(atomic.Int32))})
+	begin := time.Now()
+	start(t, s)
+
+	// Without RunOnStart the first run waits for the interval
+	time.Sleep(10 * time.Millisecond)
+	if runs.Load() != 0 {
+		t.Errorf("ran %d times before the first interval", runs.Load())
+	}
+	waitFor(t, "three runs", func() bool { return runs.Load() >= 3 })
+	if elapsed := time.Since(begin); elapsed < 90*time.Millisecond {
+		t.Errorf("three runs after %v, want at least three intervals", elapsed)
+	}
+
+	next := status(t, s, "test-jitter").NextRun
+	if next == nil {
+		t.Fatal("jittered job has no next run")
+	}
+	if delay := next.Sub(begin); delay < time.Hour || delay >= 2*time.Hour+time.Second {
+		t.Errorf("next run in %v, want the interval plus under an hour of jitter", delay)
+	}
+}
+
+func TestRunOnStart(t *testing.T) {
+	var eager, lazy atomic.Int32
+	s := New()
+	s.Register(Job{Name: "test-eager", Interval: time.Hour, RunOnStart: true, Run: counter(&eager)})
+	s.Register(Job{Name: "test-lazy", Interval: time.Hour, Run: counter(&lazy)})
+	start(t, s)
+
+	waitFor(t, "the eager job", func() bool { return status(t, s, "test-eager").RunCount == 1 })
+	if eager.Load() != 1 || lazy.Load() != 0 {
+		t.Errorf("eager ran %d times and lazy %d, want 1 and 0", eager.Load(), lazy.Load())
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	var runs atomic.Int32
+	release := make(chan struct{})
+	s := New()
+	s.Register(Job{Name: "test-idle", Interval: time.Hour, Run: counter(&runs)})
+	s.Register(Job{Name: "test-replay", Interval: time.Hour, RunOnStart: true, Run: func(context.Context) error {
+		return errors.Join(errors.New("last snapshot"), ErrDone)
+	}})
+	s.Register(Job{Name: "test-busy", Interval: time.Hour, RunOnStart: true, Run: func(context.Context) error {
+		<-release
+		return nil
+	}})
+	start(t, s)
+	defer close(release)
+
+	if err := s.Trigger("test-missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("unknown job: got %v, want ErrUnknownJob", err)
+	}
+
+	if err := s.Trigger("test-idle"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the triggered run", func() bool { return runs.Load() == 1 })
+
+	waitFor(t, "the replay to finish", func() bool { return status(t, s, "test-replay").Done })
+	if err := s.Trigger("test-replay"); !errors.Is(err, ErrDone) {
+		t.Errorf("finished job: got %v, want ErrDone", err)
+	}
+	if st := status(t, s, "test-replay"); st.RunCount != 1 || st.ErrorCount != 0 || st.NextRun != nil {
+		t.Errorf("finished job: %d runs, %d errors, next run %v; want 1, 0 and none", st.RunCount, st.ErrorCount, st.NextRun)
+	}
+
+	waitFor(t, "the busy job to start", func() bool { return status(t, s, "test-busy").Running })
+	if err := s.Trigger("test-busy"); !errors.Is(err, ErrRunning) {
+		t.Errorf("running job: got %v, want ErrRunning", err)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	var runs atomic.Int32
+	s := New()
+	s.Register(Job{Name: "test-panic", Interval: time.Hour, RunOnStart: true, Run: func(context.Context) error {
+		if runs.Add(1) == 1 {
+			panic("index out of range")
+		}
+		return nil
+	}})
+	start(t, s)
+
+	waitFor(t, "the panicking run", func() bool { return status(t, s, "test-panic").RunCount == 1 })
+	st := status(t, s, "test-panic")
+	if st.ErrorCount != 1 || !strings.Contains(st.LastError, "panic: index out of range") {
+		t.Errorf("after panic: %d errors, last %q", st.ErrorCount, st.LastError)
+	}
+
+	// The job keeps running, and a success clears the last error
+	if err := s.Trigger("test-panic"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the next run", func() bool { return status(t, s, "test-panic").RunCount == 2 })
+	if st := status(t, s, "test-panic"); st.LastError != "" || st.ErrorCount != 1 {
+		t.Errorf("after recovery: %d errors, last %q", st.ErrorCount, st.LastError)
+	}
+}
+
+func TestRunsAreRecorded(t *testing.T) {
+	name := uniqueName("test-recorded")
+	var runs atomic.Int32
+	s := New()
+	s.Register(Job{Name: name, Interval: time.Hour, RunOnStart: true, Run: func(context.Context) error {
+		if runs.Add(1) == 1 {
+			return errors.New("upstream down")
+		}
+		return nil
+	}})
+	start(t, s)
+
+	waitFor(t, "the first run", func() bool { return status(t, s, name).RunCount == 1 })
+	if err := s.Trigger(name); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the second run", func() bool { return status(t, s, name).RunCount == 2 })
+
+	store := database.NewSQLStore(database.ReadDB)
+	var recorded []database.JobRun
+	waitFor(t, "both runs to be recorded", func() bool {
+		var err error
+		recorded, err = store.JobRuns(context.Background(), name, 10)
+		return err == nil && len(recorded) == 2
+	})
+	// Newest first
+	if recorded[0].Error != "" || recorded[1].Error != "upstream down" {
+		t.Errorf("recorded errors %q and %q, want none and upstream down", recorded[0].Error, recorded[1].Error)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	name := uniqueName("test-shutdown")
+	s := New()
+	s.Register(Job{Name: name, Interval: time.Hour, RunOnStart: true, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	s.Register(Job{Name: "test-waiting", Interval: time.Hour, Run: counter(new(atomic.Int32))})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	waitFor(t, "the job to start", func() bool { return status(t, s, name).Running })
+
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Wait did not return after ctx was cancelled")
+	}
+
+	// The run cut short by shutdown is still recorded
+	runs, err := database.NewSQLStore(database.ReadDB).JobRuns(context.Background(), name, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Error != context.Canceled.Error() {
+		t.Errorf("recorded runs %+v, want one cancelled run", runs)
+	}
+}
@@ -0,0 +1,141 @@
+package scripts
+
+import (
+	"context"
+	"flipAssistant/database"
+	"log"
+	"runtime"
+	"sync"
+)
+
+// AnalyticsWorkers bounds how many items have their analytics updated at
+// once. Each worker only reads, so SQLite serves them concurrently.
+var AnalyticsWorkers = runtime.NumCPU()
+
+// UpdateAnalytics brings the analytics of the given items in one game mode up
+// to date by folding their new quotes into the stored indicator state, on a
+// bounded worker pool, then writes all results in a single transaction. Items
+// whose computation fails are logged and skipped. It returns how many items
+// were updated.
+//
+// Quotes are folded in time order, so after inserting quotes older than an
+// item's newest, as backfills and imports do, use RebuildAnalytics instead.
+func UpdateAnalytics(ctx context.Context, mode string, itemIDs []int) (int, error) {
+	return updateAnalytics(ctx, mode, itemIDs, false)
+}
+
+// RebuildAnalytics recomputes the analytics and indicator state of the given
+// items in one game mode from their whole stored history, the same way
+// UpdateAnalytics does otherwise. Quotes rolled into candles are not part of
+// that history, so items that have some are rebuilt from their remaining raw
+// quotes only, with a warning.
+func RebuildAnalytics(ctx context.Context, mode string, itemIDs []int) (int, error) {
+	warnCompacted(ctx, mode, itemIDs)
+	return updateAnalytics(ctx, mode, itemIDs, true)
+}
+
+// warnCompacted logs how many of the items about to be rebuilt have quotes
+// rolled into candles, whose indicators will lose that part of their history
+func warnCompacted(ctx context.Context, mode string, itemIDs []int) {
+	compacted, err := database.CompactedItems(ctx, mode, itemIDs)
+	if err != nil {
+		log.Printf("Error checking %s items for compacted history: %v", mode, err)
+		return
+	}
+	if len(compacted) > 0 {
+		log.Printf("Warning: %d of %d %s items have quotes rolled into candles; their analytics are rebuilt from the remaining raw quotes only",
+			len(compacted), len(itemIDs), mode)
+	}
+}
+
+func updateAnalytics(ctx context.Context, mode string, itemIDs []int, rebuild bool) (int, error) {
+	if len(itemIDs) == 0 {
+		return 0, nil
+	}
+
+	workers := AnalyticsWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(itemIDs) {
+		workers = len(itemIDs)
+	}
+
+	// computed pairs an item's analytics with its advanced indicator state
+	type computed struct {
+		analytics database.ItemAnalytics
+		state     database.IndicatorState
+	}
+
+	jobs := make(chan int)
+	results := make(chan computed)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for itemID := range jobs {
+				state := database.IndicatorState{ItemID: itemID, GameMode: mode}
+				var err error
+				if !rebuild {
+					state, err = database.LoadIndicatorState(ctx, mode, itemID)
+				}
+				var analytics database.ItemAnalytics
+				if err == nil {
+					analytics, state, err = database.ComputeItemAnalytics(ctx, mode, state)
+				}
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("Error updating analytics for item %d: %v", itemID, err)
+					}
+					continue
+				}
+				results <- computed{analytics, state}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, itemID := range itemIDs {
+			select {
+			case jobs <- itemID:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	updates := make([]computed, 0, len(itemIDs))
+	for result := range results {
+		updates = append(updates, result)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, update := range updates {
+		if err := database.StoreItemAnalytics(ctx, tx, update.analytics); err != nil {
+			return 0, err
+		}
+		if err := database.StoreIndicatorState(ctx, tx, update.state); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(updates), nil
+}
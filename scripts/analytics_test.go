@@ -0,0 +1,88 @@
+package scripts
+
+import (
+	"context"
+	"flipAssistant/database"
+	"math"
+	"testing"
+	"time"
+)
+
+// itemAnalytics returns the stored analytics of one item
+func itemAnalytics(t *testing.T, mode string, itemID int) database.ItemAnalytics {
+	t.Helper()
+	analytics, err := database.NewSQLStore(database.ReadDB).Analytics(context.Background(), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range analytics {
+		if a.ItemID == itemID {
+			return a
+		}
+	}
+	t.Fatalf("no analytics for item %d", itemID)
+	return database.ItemAnalytics{}
+}
+
+// sameAnalytics compares analytics allowing for floating-point rounding
+func sameAnalytics(a, b database.ItemAnalytics) bool {
+	fields := func(x database.ItemAnalytics) []float64 {
+		out := []float64{x.SMA5Buy, x.SMA5Sell}
+		for _, series := range database.IndicatorSeries {
+			in := x.Series(series)
+			out = append(out, in.RSI, in.MACDLine, in.MACDSignal, in.MACDHist)
+		}
+		return out
+	}
+	fa, fb := fields(a), fields(b)
+	for i := range fa {
+		if math.Abs(fa[i]-fb[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+// resumeRuns counts runs of TestUpdateAnalyticsResumes, each using a new
+// item as the database keeps the quotes of earlier ones
+var resumeRuns int
+
+func TestUpdateAnalyticsResumes(t *testing.T) {
+	resumeRuns++
+	itemID := 920100 + resumeRuns
+	ctx := context.Background()
+	start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	// Quotes arrive in batches, the analytics being updated after each, so
+	// every update after the first resumes from the stored state
+	var buys, sells []float64
+	for _, batch := range []int{10, 1, 20, 7, 35} {
+		var rows []database.PriceRow
+		for i := 0; i < batch; i++ {
+			n := len(buys)
+			buy, sell := 1000+int(50*math.Sin(float64(n)/4)), 1040+int(30*math.Cos(float64(n)/3))
+			rows = append(rows, database.PriceRow{ItemID: itemID, GameMode: "osrs",
+				Timestamp: start.Add(time.Duration(n) * 10 * time.Minute), BuyPrice: buy, SellPrice: sell, Quality: QualityOK})
+			buys, sells = append(buys, float64(buy)), append(sells, float64(sell))
+		}
+		if _, err := database.ImportPrices(ctx, rows); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := UpdateAnalytics(ctx, "osrs", []int{itemID}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := database.AnalyticsFromPrices("osrs", itemID, buys, sells)
+		if got := itemAnalytics(t, "osrs", itemID); !sameAnalytics(got, want) {
+			t.Errorf("after %d quotes: updated %+v, want the full recompute %+v", len(buys), got, want)
+		}
+	}
+
+	updated := itemAnalytics(t, "osrs", itemID)
+	if _, err := RebuildAnalytics(ctx, "osrs", []int{itemID}); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt := itemAnalytics(t, "osrs", itemID); !sameAnalytics(updated, rebuilt) {
+		t.Errorf("updated %+v, rebuilt %+v", updated, rebuilt)
+	}
+}
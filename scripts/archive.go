@@ -0,0 +1,115 @@
+package scripts
+
+import (
+	"context"
+	"errors"
+	"flipAssistant/database"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// archiveSnapshot keeps a raw API response so it can be reprocessed later.
+// Failures are logged rather than returned so they never block ingestion.
+func archiveSnapshot(ctx context.Context, mode, endpoint string, body []byte) {
+	if err := database.StoreSnapshot(ctx, mode, endpoint, time.Now(), body); err != nil {
+		log.Printf("Error archiving %s %s snapshot: %v", mode, endpoint, err)
+	}
+}
+
+// ArchiveSource steps through a game mode's archived /latest snapshots in the
+// order they were fetched, optionally limited to [Since, Until). After the
+// last snapshot FetchLatest returns io.EOF.
+type ArchiveSource struct {
+	Mode  string
+	Since time.Time
+	Until time.Time
+
+	lastID int64
+}
+
+// NewArchiveSource creates a source over the archived snapshots in the range;
+// zero times leave that end open
+func NewArchiveSource(mode string, since, until time.Time) *ArchiveSource {
+	return &ArchiveSource{Mode: mode, Since: since, Until: until}
+}
+
+func (s *ArchiveSource) Name() string { return "archive" }
+
+func (s *ArchiveSource) FetchLatest(ctx context.Context) (map[string]OSRSItem, error) {
+	snapshot, err := database.NextSnapshot(ctx, s.Mode, "latest", s.lastID, s.Since, s.Until)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, io.EOF
+	}
+	s.lastID = snapshot.ID
+	return parseLatest(snapshot.Payload)
+}
+
+// ReprocessArchive rebuilds item_prices and item_analytics for the given items
+// in one game mode from its archived /latest snapshots. With reset, the items'
+// existing prices and analytics are deleted first; otherwise snapshots are
+// merged in, and quotes that are already stored are skipped.
+func ReprocessArchive(ctx context.Context, mode string, itemIDs []int, since, until time.Time, reset bool) error {
+	if reset {
+		if err := deleteItemData(ctx, mode, itemIDs); err != nil {
+			return fmt.Errorf("error clearing existing data: %v", err)
+		}
+	}
+
+	source := NewArchiveSource(mode, since, until)
+	snapshots, inserted, rejected := 0, 0, 0
+	for {
+		prices, err := source.FetchLatest(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		snapshots++
+
+		stats, err := storeSnapshot(ctx, mode, itemIDs, prices)
+		if err != nil {
+			return fmt.Errorf("error storing snapshot %d: %v", snapshots, err)
+		}
+		inserted += len(stats.Inserted)
+		rejected += stats.Rejected
+	}
+
+	// Replayed quotes can be older than stored ones, so rebuild the
+	// indicators once per item from the final history
+	if _, err := RebuildAnalytics(ctx, mode, itemIDs); err != nil {
+		return err
+	}
+
+	log.Printf("Reprocess complete: %d snapshots replayed, %d rows inserted, %d quotes quarantined for %d items",
+		snapshots, inserted, rejected, len(itemIDs))
+	return nil
+}
+
+// deleteItemData removes the stored prices, analytics and indicator state for
+// the given items in one game mode
+func deleteItemData(ctx context.Context, mode string, itemIDs []int) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, itemID := range itemIDs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM item_prices WHERE item_id = ? AND game_mode = ?`, itemID, mode); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM item_analytics WHERE item_id = ? AND game_mode = ?`, itemID, mode); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM item_indicator_state WHERE item_id = ? AND game_mode = ?`, itemID, mode); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
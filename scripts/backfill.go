@@ -0,0 +1,133 @@
+package scripts
+
+import (
+	"context"
+	"encoding/json"
+	"flipAssistant/database"
+	"fmt"
+	"log"
+	"time"
+)
+
+// TimeseriesPoint represents one bucket returned by the /timeseries endpoint
+type TimeseriesPoint struct {
+	Timestamp       int64 `json:"timestamp"`
+	AvgHighPrice    int   `json:"avgHighPrice"`
+	AvgLowPrice     int   `json:"avgLowPrice"`
+	HighPriceVolume int   `json:"highPriceVolume"`
+	LowPriceVolume  int   `json:"lowPriceVolume"`
+}
+
+// TimeseriesSteps lists the timesteps accepted by the /timeseries endpoint
+var TimeseriesSteps = map[string]bool{"5m": true, "1h": true, "6h": true}
+
+// BackfillItemHistory pulls historical buckets from a game mode's /timeseries
+// at the wiki API root baseURL for each item, merges them into item_prices and recomputes analytics, so
+// indicators are available immediately instead of after many fetch cycles.
+// delay is waited between items to stay within the API's usage guidelines.
+func BackfillItemHistory(ctx context.Context, baseURL, mode string, itemIDs []int, timestep string, delay time.Duration) error {
+	if !TimeseriesSteps[timestep] {
+		return fmt.Errorf("unsupported timestep %q, expected 5m, 1h or 6h", timestep)
+	}
+
+	client := NewFetchClient()
+	warnCompacted(ctx, mode, itemIDs)
+
+	totalInserted := 0
+	for i, itemID := range itemIDs {
+		if i > 0 {
+			if err := sleepContext(ctx, delay); err != nil {
+				return err
+			}
+		}
+
+		points, err := fetchTimeseries(ctx, client, baseURL, mode, itemID, timestep)
+		if err != nil {
+			log.Printf("Backfill failed for item %d: %v", itemID, err)
+			continue
+		}
+
+		inserted, err := mergeTimeseries(ctx, mode, itemID, points)
+		if err != nil {
+			log.Printf("Error merging backfill for item %d: %v", itemID, err)
+			continue
+		}
+
+		if err := database.RebuildItemAnalytics(ctx, mode, itemID); err != nil {
+			log.Printf("Error updating analytics for item %d: %v", itemID, err)
+		}
+
+		log.Printf("Backfilled item %d: %d of %d buckets inserted", itemID, inserted, len(points))
+		totalInserted += inserted
+	}
+
+	log.Printf("Backfill complete: %d rows inserted for %d items", totalInserted, len(itemIDs))
+	return nil
+}
+
+// fetchTimeseries downloads the /timeseries buckets for a single item
+func fetchTimeseries(ctx context.Context, client *FetchClient, baseURL, mode string, itemID int, timestep string) ([]TimeseriesPoint, error) {
+	body, err := client.Get(ctx, wikiURL(baseURL, mode, "timeseries"), map[string]string{
+		"id":       fmt.Sprintf("%d", itemID),
+		"timestep": timestep,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data []TimeseriesPoint `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error parsing timeseries response: %v", err)
+	}
+	return response.Data, nil
+}
+
+// mergeTimeseries inserts the buckets as item_prices rows, skipping buckets
+// where a side did not trade and timestamps that are already stored
+func mergeTimeseries(ctx context.Context, mode string, itemID int, points []TimeseriesPoint) (int, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	exists, err := tx.PrepareContext(ctx, `
+		SELECT COUNT(*) FROM item_prices WHERE item_id = ? AND game_mode = ? AND timestamp = ?
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer exists.Close()
+
+	insert, err := tx.PrepareContext(ctx, `
+		INSERT INTO item_prices (item_id, game_mode, timestamp, buy_price, sell_price, buy_time, sell_time, quality)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	inserted := 0
+	for _, p := range points {
+		if p.AvgLowPrice == 0 || p.AvgHighPrice == 0 {
+			continue
+		}
+		timestamp := database.FormatTimestamp(p.Timestamp)
+		var stored int
+		if err := exists.QueryRowContext(ctx, itemID, mode, timestamp).Scan(&stored); err != nil {
+			return 0, err
+		}
+		if stored > 0 {
+			continue
+		}
+		if _, err := insert.ExecContext(ctx, itemID, mode, timestamp, p.AvgLowPrice, p.AvgHighPrice, timestamp, timestamp, QualityAveraged); err != nil {
+			return 0, err
+		}
+		inserted++
+	}
+
+	return inserted, tx.Commit()
+}
@@ -1,12 +1,14 @@
 package scripts
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flipAssistant/database"
 	"fmt"
+	"io"
 	"log"
-
-	"github.com/go-resty/resty/v2"
+	"time"
 )
 
 // OSRSItem represents an item price data structure
@@ -17,90 +19,251 @@ type OSRSItem struct {
 	LowTime  int `json:"lowTime"`  // Timestamp for low price
 }
 
-// FetchAndStorePricesForAllItems fetches prices for all tracked items in a single API call
-func FetchAndStorePricesForAllItems(itemIDs []int) {
-	client := resty.New()
+// OSRSAverage represents an averaged price bucket with traded volumes
+type OSRSAverage struct {
+	AvgHighPrice    int `json:"avgHighPrice"`    // Average instant-buy price
+	AvgLowPrice     int `json:"avgLowPrice"`     // Average instant-sell price
+	HighPriceVolume int `json:"highPriceVolume"` // Units traded at the high price
+	LowPriceVolume  int `json:"lowPriceVolume"`  // Units traded at the low price
+}
 
-	// Set proper User-Agent to be respectful to the API (as required by OSRS Wiki)
-	client.SetHeader("User-Agent", "FlipAssistant/1.0 - OSRS GE Flip Analysis Tool - Contact: github.com/VuntsJaHabe/flipAssistant")
+// userAgent identifies us to the OSRS Wiki API, as its usage guidelines require
+const userAgent = "FlipAssistant/1.0 - OSRS GE Flip Analysis Tool - Contact: github.com/VuntsJaHabe/flipAssistant"
 
-	resp, err := client.R().Get("https://prices.runescape.wiki/api/v1/osrs/latest")
+// FetchAndStorePricesForAllItems fetches prices for all tracked items in a
+// single snapshot from the given source and stores them under the game mode.
+// Individual items that fail to store are logged and skipped; an error is
+// returned when no snapshot could be fetched or ctx is cancelled part-way
+// through.
+func FetchAndStorePricesForAllItems(ctx context.Context, source PriceSource, mode string, itemIDs []int) error {
+	prices, err := source.FetchLatest(ctx)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("no more snapshots from %s source: %w", source.Name(), err)
+	}
 	if err != nil {
-		log.Println("Failed to fetch OSRS GE prices:", err)
-		return
+		return fmt.Errorf("failed to fetch OSRS GE prices from %s source: %v", source.Name(), err)
 	}
 
-	var response struct {
-		Data map[string]OSRSItem `json:"data"`
+	start := time.Now()
+
+	// Insert the whole snapshot in one transaction, so a failure or shutdown
+	// part-way through leaves no half-written batch behind
+	stats, err := storeSnapshot(ctx, mode, itemIDs, prices)
+	if err != nil {
+		return fmt.Errorf("error storing price snapshot: %v", err)
+	}
+	insertDuration := time.Since(start)
+
+	// Items whose quote didn't change keep their analytics
+	updated, err := UpdateAnalytics(ctx, mode, stats.Inserted)
+	if err != nil {
+		return err
 	}
 
-	// Parse JSON response
-	if err := json.Unmarshal(resp.Body(), &response); err != nil {
-		log.Println("Error parsing API response:", err)
-		return
+	log.Printf("%s price update complete in %v (insert %v, analytics %v): %d items updated, %d unchanged, %d quarantined, %d items not found in API",
+		mode, time.Since(start).Round(time.Millisecond), insertDuration.Round(time.Millisecond),
+		(time.Since(start) - insertDuration).Round(time.Millisecond), updated, stats.Unchanged, stats.Rejected, stats.NotFound)
+	return nil
+}
+
+// snapshotStats summarises what storeSnapshot did with each tracked item
+type snapshotStats struct {
+	Inserted  []int // Items that received a new row
+	Unchanged int   // Items whose quote was already stored
+	Rejected  int   // Items whose quote failed the quality gate
+	NotFound  int   // Items missing from the snapshot
+}
+
+// storeSnapshot validates the tracked items' quotes from one game mode's
+// snapshot and inserts those that pass in a single transaction; the rest are
+// quarantined
+func storeSnapshot(ctx context.Context, mode string, itemIDs []int, prices map[string]OSRSItem) (snapshotStats, error) {
+	var stats snapshotStats
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, err
 	}
+	defer tx.Rollback()
 
-	successCount := 0
-	notFoundCount := 0
+	reference := snapshotReference(prices)
 
 	// Process all our tracked items from the single API response
 	for _, itemID := range itemIDs {
-		itemKey := fmt.Sprintf("%d", itemID)
-		if itemData, exists := response.Data[itemKey]; exists {
-			// Insert new price data
-			_, err := database.DB.Exec(`
-				INSERT INTO item_prices (item_id, buy_price, sell_price) 
-				VALUES (?, ?, ?)
-			`, itemID, itemData.Low, itemData.High)
-			if err != nil {
-				log.Printf("Error inserting data for item %d: %v", itemID, err)
-				continue
-			}
+		itemData, exists := prices[fmt.Sprintf("%d", itemID)]
+		if !exists {
+			stats.NotFound++
+			continue
+		}
 
-			// Update analytics
-			if err := database.UpdateItemAnalytics(itemID); err != nil {
-				log.Printf("Error updating analytics for item %d: %v", itemID, err)
+		// Repeats are skipped before validation, so a stale quote that stays
+		// in /latest for days is only judged once
+		stored, err := quoteStored(ctx, tx, mode, itemID, itemData)
+		if err != nil {
+			return stats, fmt.Errorf("item %d: %v", itemID, err)
+		}
+		if stored {
+			// Neither side has traded since the last stored quote
+			stats.Unchanged++
+			continue
+		}
+
+		flag, reason, err := Quality.Check(ctx, tx, mode, itemID, itemData, reference)
+		if err != nil {
+			return stats, fmt.Errorf("item %d: %v", itemID, err)
+		}
+		if reason != "" {
+			if err := quarantineQuote(ctx, tx, mode, itemID, itemData, reason); err != nil {
+				return stats, fmt.Errorf("item %d: %v", itemID, err)
 			}
-			successCount++
-		} else {
-			notFoundCount++
+			stats.Rejected++
+			continue
 		}
+
+		ok, err := insertQuote(ctx, tx, mode, itemID, itemData, flag)
+		if err != nil {
+			return stats, fmt.Errorf("item %d: %v", itemID, err)
+		}
+		if !ok {
+			stats.Unchanged++
+			continue
+		}
+		stats.Inserted = append(stats.Inserted, itemID)
 	}
 
-	log.Printf("Price update complete: %d items updated, %d items not found in API", successCount, notFoundCount)
+	if err := tx.Commit(); err != nil {
+		return snapshotStats{}, err
+	}
+	return stats, nil
 }
 
-// Fetch5MinuteAverages fetches 5-minute price averages for better trend analysis
-func Fetch5MinuteAverages(itemIDs []int) {
-	client := resty.New()
-	client.SetHeader("User-Agent", "FlipAssistant/1.0 - OSRS GE Flip Analysis Tool - Contact: github.com/user/flipAssistant")
+// quoteStored reports whether a quote with the same trade times is already
+// stored for the item, whether neither side has ever traded, or whether the
+// quote falls in a day already rolled into candles. A quote that stays in
+// /latest past compaction no longer has a raw row to match, and storing it
+// again would count it twice once it is compacted.
+func quoteStored(ctx context.Context, q database.Querier, mode string, itemID int, quote OSRSItem) (bool, error) {
+	observed := quote.HighTime
+	if quote.LowTime > observed {
+		observed = quote.LowTime
+	}
+	if observed == 0 {
+		return true, nil
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT 1 FROM item_prices
+		WHERE item_id = ? AND game_mode = ? AND buy_time IS NOT DISTINCT FROM ? AND sell_time IS NOT DISTINCT FROM ?
+		LIMIT 1
+	`, itemID, mode, nullableTimestamp(quote.LowTime), nullableTimestamp(quote.HighTime))
+	if err != nil {
+		return false, err
+	}
+	stored := rows.Next()
+	rows.Close()
+	if err := rows.Err(); err != nil || stored {
+		return stored, err
+	}
+	return database.Compacted(ctx, q, mode, itemID, time.Unix(int64(observed), 0))
+}
+
+// insertQuote stores a quote observed at the time its most recent side traded,
+// with each side's own trade time and the quality flag it passed the gate with.
+// Callers skip repeats of an already stored quote with quoteStored first.
+func insertQuote(ctx context.Context, exec database.Execer, mode string, itemID int, quote OSRSItem, quality string) (bool, error) {
+	observed := quote.HighTime
+	if quote.LowTime > observed {
+		observed = quote.LowTime
+	}
+	if observed == 0 {
+		return false, nil // Neither side has ever traded
+	}
+
+	res, err := exec.ExecContext(ctx, `
+		INSERT INTO item_prices (item_id, game_mode, timestamp, buy_price, sell_price, buy_time, sell_time, quality)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, itemID, mode, database.FormatTimestamp(int64(observed)), quote.Low, quote.High,
+		nullableTimestamp(quote.LowTime), nullableTimestamp(quote.HighTime), quality)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// nullableTimestamp formats an API trade time, mapping 0 (never traded) to NULL
+func nullableTimestamp(unix int) interface{} {
+	if unix == 0 {
+		return nil
+	}
+	return database.FormatTimestamp(int64(unix))
+}
 
-	resp, err := client.R().Get("https://prices.runescape.wiki/api/v1/osrs/5m")
+// FetchAverages fetches a game mode's averaged prices and trade volumes for one
+// resolution (5m, 1h or 24h) from the wiki API root baseURL and stores them
+// under the bucket timestamp reported by the API
+func FetchAverages(ctx context.Context, baseURL, mode, resolution string, itemIDs []int) error {
+	table, ok := database.AverageTables[resolution]
+	if !ok {
+		return fmt.Errorf("unknown average resolution %q", resolution)
+	}
+
+	body, err := NewFetchClient().Get(ctx, wikiURL(baseURL, mode, resolution), nil)
 	if err != nil {
-		log.Println("Failed to fetch 5-minute averages:", err)
-		return
+		return fmt.Errorf("failed to fetch %s %s averages: %v", mode, resolution, err)
 	}
+	archiveSnapshot(ctx, mode, resolution, body)
 
 	var response struct {
-		Data map[string]struct {
-			AvgHighPrice    int `json:"avgHighPrice"`
-			AvgLowPrice     int `json:"avgLowPrice"`
-			HighPriceVolume int `json:"highPriceVolume"`
-			LowPriceVolume  int `json:"lowPriceVolume"`
-		} `json:"data"`
-		Timestamp int64 `json:"timestamp"`
+		Data      map[string]OSRSAverage `json:"data"`
+		Timestamp int64                  `json:"timestamp"`
 	}
 
-	if err := json.Unmarshal(resp.Body(), &response); err != nil {
-		log.Println("Error parsing 5m API response:", err)
-		return
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("error parsing %s API response: %v", resolution, err)
 	}
 
-	// This could be used for volume-based flip suggestions in the future
-	log.Printf("Fetched 5-minute averages with %d items at timestamp %d", len(response.Data), response.Timestamp)
+	stored, err := storeAverages(ctx, table, mode, itemIDs, response.Data, response.Timestamp)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s %s averages stored: %d items at bucket %s", mode, resolution, stored, database.FormatTimestamp(response.Timestamp))
+	return nil
 }
 
-// Legacy function for single item (now deprecated, but kept for compatibility)
-func FetchAndStorePrices(itemID int) {
-	FetchAndStorePricesForAllItems([]int{itemID})
+// storeAverages writes one bucket of averaged prices for the tracked items into
+// the given table, skipping buckets that are already stored.
+func storeAverages(ctx context.Context, table, mode string, itemIDs []int, data map[string]OSRSAverage, bucket int64) (int, error) {
+	timestamp := database.FormatTimestamp(bucket)
+	query := fmt.Sprintf(`
+		INSERT INTO %s (item_id, game_mode, timestamp, avg_high_price, avg_low_price, high_price_volume, low_price_volume)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, table)
+
+	stored := 0
+	for _, itemID := range itemIDs {
+		if err := ctx.Err(); err != nil {
+			return stored, err
+		}
+		avg, exists := data[fmt.Sprintf("%d", itemID)]
+		if !exists {
+			continue
+		}
+		_, err := database.DB.ExecContext(ctx, query, itemID, mode, timestamp,
+			nullIfZero(avg.AvgHighPrice), nullIfZero(avg.AvgLowPrice),
+			avg.HighPriceVolume, avg.LowPriceVolume)
+		if err != nil {
+			log.Printf("Error inserting %s data for item %d: %v", table, itemID, err)
+			continue
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// nullIfZero maps the API's missing average (null, decoded as 0) to SQL NULL
+func nullIfZero(v int) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
 }
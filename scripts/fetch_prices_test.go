@@ -0,0 +1,85 @@
+package scripts
+
+import (
+	"context"
+	"flipAssistant/database"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStoreSnapshotSkipsCompactedQuotes(t *testing.T) {
+	ctx := context.Background()
+	const itemID = 910001
+	traded := int(time.Now().Add(-72 * time.Hour).Unix())
+	prices := map[string]OSRSItem{"910001": {High: 110, HighTime: traded, Low: 100, LowTime: traded}}
+
+	stats, err := storeSnapshot(ctx, "osrs", []int{itemID}, prices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Inserted) != 1 {
+		t.Fatalf("first snapshot inserted %v, want the item", stats.Inserted)
+	}
+	if _, err := database.CompactPrices(ctx, 24*time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// /latest still lists the same quote after its day was rolled up
+	stats, err = storeSnapshot(ctx, "osrs", []int{itemID}, prices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Inserted) != 0 || stats.Unchanged != 1 {
+		t.Errorf("stale quote: inserted %v, %d unchanged, want it skipped", stats.Inserted, stats.Unchanged)
+	}
+
+	var raw, ticks int
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM item_prices WHERE item_id = ?`, itemID).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.QueryRow(`SELECT SUM(ticks) FROM item_candles_1d WHERE item_id = ?`, itemID).Scan(&ticks); err != nil {
+		t.Fatal(err)
+	}
+	if raw != 0 || ticks != 1 {
+		t.Errorf("got %d raw rows and %d candle ticks, want 0 and 1", raw, ticks)
+	}
+
+	// A newer quote for a day that has not been compacted is still stored
+	fresh := int(time.Now().Unix())
+	prices["910001"] = OSRSItem{High: 111, HighTime: fresh, Low: 101, LowTime: fresh}
+	if stats, err = storeSnapshot(ctx, "osrs", []int{itemID}, prices); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Inserted) != 1 {
+		t.Errorf("fresh quote inserted %v, want the item", stats.Inserted)
+	}
+}
+
+func TestFetchAveragesUsesBaseURL(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data":{"910002":{"avgHighPrice":120,"avgLowPrice":110,"highPriceVolume":5,"lowPriceVolume":7}},"timestamp":1773489600}`)
+	}))
+	defer srv.Close()
+
+	if err := FetchAverages(context.Background(), srv.URL+"/api/v1", "dmm", "1h", []int{910002}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/api/v1/dmm/1h" {
+		t.Errorf("requested %q, want /api/v1/dmm/1h", path)
+	}
+
+	var high, volume int
+	err := database.DB.QueryRow(`SELECT avg_high_price, low_price_volume FROM item_averages_1h WHERE item_id = ? AND game_mode = ?`, 910002, "dmm").Scan(&high, &volume)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if high != 120 || volume != 7 {
+		t.Errorf("stored high %d and low volume %d, want 120 and 7", high, volume)
+	}
+}
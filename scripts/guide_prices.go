@@ -0,0 +1,146 @@
+package scripts
+
+import (
+	"context"
+	"encoding/json"
+	"flipAssistant/database"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ItemDBBaseURL is the root of the official Old School Grand Exchange itemdb API
+const ItemDBBaseURL = "https://secure.runescape.com/m=itemdb_oldschool"
+
+// JagexSource reads the official Grand Exchange guide prices from the itemdb
+// API. The itemdb only serves one item per request, so Delay paces requests to
+// stay within its rate limits. Guide prices are a single daily value, not a
+// live quote, so they are stored separately in guide_prices and used to
+// cross-check the wiki prices rather than to drive flips.
+type JagexSource struct {
+	BaseURL string
+	Delay   time.Duration
+	client  *FetchClient
+}
+
+// NewJagexSource creates a source for the itemdb API at baseURL
+func NewJagexSource(baseURL string, delay time.Duration) *JagexSource {
+	return &JagexSource{BaseURL: baseURL, Delay: delay, client: NewFetchClient()}
+}
+
+func (s *JagexSource) Name() string { return "itemdb" }
+
+// FetchLatest returns each tradeable item's most recent guide price as a quote
+// with both sides at the guide price, so the itemdb can stand in wherever a
+// PriceSource is expected. Items the itemdb doesn't know are left out.
+func (s *JagexSource) FetchLatest(ctx context.Context) (map[string]OSRSItem, error) {
+	quotes := make(map[string]OSRSItem)
+	err := s.eachItem(ctx, database.GetAllTradeableItems(), func(itemID int, prices []database.GuidePrice) error {
+		latest := prices[len(prices)-1]
+		traded := int(latest.Date.Unix())
+		quotes[strconv.Itoa(itemID)] = OSRSItem{High: latest.Price, HighTime: traded, Low: latest.Price, LowTime: traded}
+		return nil
+	})
+	return quotes, err
+}
+
+// FetchGuidePrices returns an item's daily guide prices for the last 180 days,
+// oldest first
+func (s *JagexSource) FetchGuidePrices(ctx context.Context, itemID int) ([]database.GuidePrice, error) {
+	body, err := s.client.Get(ctx, fmt.Sprintf("%s/api/graph/%d.json", s.BaseURL, itemID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Daily guide prices keyed by unix time in milliseconds
+	var response struct {
+		Daily map[string]int `json:"daily"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error parsing itemdb graph for item %d: %v", itemID, err)
+	}
+
+	prices := make([]database.GuidePrice, 0, len(response.Daily))
+	for key, price := range response.Daily {
+		ms, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("itemdb graph for item %d has invalid timestamp %q", itemID, key)
+		}
+		prices = append(prices, database.GuidePrice{Date: time.UnixMilli(ms).UTC(), Price: price})
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Date.Before(prices[j].Date)
+	})
+	return prices, nil
+}
+
+// eachItem fetches the guide prices of each item in turn, pausing Delay
+// between requests. Items that fail are logged and skipped; an error is
+// returned when ctx is cancelled or no item could be fetched.
+func (s *JagexSource) eachItem(ctx context.Context, itemIDs []int, fn func(itemID int, prices []database.GuidePrice) error) error {
+	fetched := 0
+	for i, itemID := range itemIDs {
+		if i > 0 {
+			if err := sleepContext(ctx, s.Delay); err != nil {
+				return err
+			}
+		}
+
+		prices, err := s.FetchGuidePrices(ctx, itemID)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("Error fetching guide prices for item %d: %v", itemID, err)
+			continue
+		}
+		if len(prices) == 0 {
+			continue
+		}
+		if err := fn(itemID, prices); err != nil {
+			return err
+		}
+		fetched++
+	}
+
+	if fetched == 0 && len(itemIDs) > 0 {
+		return fmt.Errorf("no guide prices could be fetched from the itemdb")
+	}
+	return nil
+}
+
+// FetchAndStoreGuidePrices downloads the guide price history of each item from
+// the itemdb into guide_prices. Items that already have today's guide price
+// are skipped, so a restart doesn't fetch every item again. Each item is
+// stored as soon as it is fetched, so a cancelled run keeps the items it got
+// through.
+func FetchAndStoreGuidePrices(ctx context.Context, source *JagexSource, itemIDs []int) error {
+	start := time.Now()
+	current, err := database.GuidePricedItems(ctx, time.Now().UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return err
+	}
+	var pending []int
+	for _, itemID := range itemIDs {
+		if !current[itemID] {
+			pending = append(pending, itemID)
+		}
+	}
+
+	items, rows := 0, 0
+	err = source.eachItem(ctx, pending, func(itemID int, prices []database.GuidePrice) error {
+		stored, err := database.StoreGuidePrices(ctx, itemID, prices)
+		if err != nil {
+			return fmt.Errorf("error storing guide prices for item %d: %v", itemID, err)
+		}
+		items++
+		rows += stored
+		return nil
+	})
+
+	log.Printf("Guide prices updated in %v: %d of %d items (%d already current), %d daily prices stored",
+		time.Since(start).Round(time.Second), items, len(pending), len(itemIDs)-len(pending), rows)
+	return err
+}
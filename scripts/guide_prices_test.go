@@ -0,0 +1,156 @@
+package scripts
+
+import (
+	"context"
+	"flipAssistant/database"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// itemDB is a stand-in for the itemdb graph API serving canned bodies per
+// item and counting the requests for each
+type itemDB struct {
+	mu       sync.Mutex
+	graphs   map[int]string
+	types    map[int]string
+	requests map[int]int
+}
+
+func newItemDB(t *testing.T, graphs map[int]string) (*itemDB, *httptest.Server) {
+	db := &itemDB{graphs: graphs, types: make(map[int]string), requests: make(map[int]int)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var itemID int
+		if _, err := fmt.Sscanf(r.URL.Path, "/api/graph/%d.json", &itemID); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		db.mu.Lock()
+		db.requests[itemID]++
+		body, ok := db.graphs[itemID]
+		contentType := db.types[itemID]
+		db.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		w.Header().Set("Content-Type", contentType)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return db, srv
+}
+
+func (db *itemDB) requestCount(itemID int) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.requests[itemID]
+}
+
+// graph builds an itemdb graph body from daily prices keyed by date
+func graph(daily map[time.Time]int) string {
+	var entries []string
+	for date, price := range daily {
+		entries = append(entries, fmt.Sprintf("%q: %d", fmt.Sprint(date.UnixMilli()), price))
+	}
+	return `{"daily": {` + strings.Join(entries, ", ") + `}, "average": {}}`
+}
+
+func TestFetchGuidePrices(t *testing.T) {
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	_, srv := newItemDB(t, map[int]string{
+		2: graph(map[time.Time]int{day: 210, day.AddDate(0, 0, -2): 190, day.AddDate(0, 0, -1): 200}),
+	})
+
+	prices, err := NewJagexSource(srv.URL, 0).FetchGuidePrices(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []database.GuidePrice{
+		{Date: day.AddDate(0, 0, -2), Price: 190},
+		{Date: day.AddDate(0, 0, -1), Price: 200},
+		{Date: day, Price: 210},
+	}
+	if len(prices) != len(want) {
+		t.Fatalf("got %d prices, want %d", len(prices), len(want))
+	}
+	for i := range want {
+		if !prices[i].Date.Equal(want[i].Date) || prices[i].Price != want[i].Price {
+			t.Errorf("price %d = %+v, want %+v", i, prices[i], want[i])
+		}
+	}
+}
+
+func TestFetchGuidePricesErrors(t *testing.T) {
+	db, srv := newItemDB(t, map[int]string{
+		3: `{"daily": {"yesterday": 5}}`,
+		4: `not json`,
+		5: `<html>Service unavailable</html>`,
+	})
+	db.types[5] = "text/html"
+	source := NewJagexSource(srv.URL, 0)
+
+	tests := []struct {
+		name   string
+		itemID int
+		want   string
+	}{
+		{"invalid timestamp", 3, "invalid timestamp"},
+		{"malformed body", 4, "error parsing itemdb graph"},
+		{"html page", 5, "unexpected content type"},
+		{"unknown item", 6, "HTTP 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := source.FetchGuidePrices(context.Background(), tt.itemID)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.want)
+			}
+			// None of these improve on a second try
+			if n := db.requestCount(tt.itemID); n != 1 {
+				t.Errorf("item %d requested %d times, want 1", tt.itemID, n)
+			}
+		})
+	}
+}
+
+func TestFetchAndStoreGuidePrices(t *testing.T) {
+	ctx := context.Background()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	db, srv := newItemDB(t, map[int]string{
+		10: graph(map[time.Time]int{today.AddDate(0, 0, -1): 1000, today: 1100}),
+		11: graph(map[time.Time]int{today.AddDate(0, 0, -1): 50}),
+		12: `{"daily": {}}`,
+	})
+	source := NewJagexSource(srv.URL, 0)
+
+	// Items 12 (no prices) and 13 (unknown) are skipped without failing the run
+	if err := FetchAndStoreGuidePrices(ctx, source, []int{10, 11, 12, 13}); err != nil {
+		t.Fatal(err)
+	}
+	var stored int
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM guide_prices WHERE item_id IN (10, 11, 12, 13)`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != 3 {
+		t.Errorf("stored %d guide prices, want 3", stored)
+	}
+
+	// Item 10 already has today's price; item 11 is still waiting for it
+	if err := FetchAndStoreGuidePrices(ctx, source, []int{10, 11}); err != nil {
+		t.Fatal(err)
+	}
+	if n := db.requestCount(10); n != 1 {
+		t.Errorf("item 10 requested %d times, want 1", n)
+	}
+	if n := db.requestCount(11); n != 2 {
+		t.Errorf("item 11 requested %d times, want 2", n)
+	}
+}
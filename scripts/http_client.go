@@ -0,0 +1,175 @@
+package scripts
+
+import (
+	"bytes"
+	"context"
+	"flipAssistant/database"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// FetchClient performs GET requests against the prices API with timeouts,
+// status validation and retries. Transient failures (network errors, 429 and
+// 5xx responses) are retried with exponential backoff and jitter, honouring
+// Retry-After when the server sends it. Requests that still fail are recorded
+// in the fetch_failures table.
+type FetchClient struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+
+	client *resty.Client
+}
+
+// NewFetchClient creates a client with the default timeout and retry policy
+func NewFetchClient() *FetchClient {
+	client := resty.New()
+	client.SetTimeout(30 * time.Second)
+
+	// Set proper User-Agent to be respectful to the API (as required by OSRS Wiki)
+	client.SetHeader("User-Agent", userAgent)
+
+	return &FetchClient{
+		MaxRetries: 4,
+		BaseDelay:  time.Second,
+		MaxDelay:   time.Minute,
+		client:     client,
+	}
+}
+
+// fetchError describes a failed request and whether it is worth retrying
+type fetchError struct {
+	status    int
+	err       error
+	retryable bool
+	wait      time.Duration // Server-requested delay from Retry-After, if any
+}
+
+func (e *fetchError) Error() string {
+	if e.status != 0 {
+		return fmt.Sprintf("HTTP %d: %v", e.status, e.err)
+	}
+	return e.err.Error()
+}
+
+// Get fetches url with the given query parameters and returns the JSON body.
+// Cancelling ctx aborts the request and any pending retry.
+func (c *FetchClient) Get(ctx context.Context, url string, query map[string]string) ([]byte, error) {
+	var lastErr *fetchError
+	attempts := 0
+
+	for attempts <= c.MaxRetries {
+		if attempts > 0 {
+			delay := c.backoff(attempts)
+			if lastErr.wait > 0 {
+				// A Retry-After of hours would stall the job that asked, so the
+				// server's request is capped like our own backoff
+				delay = min(lastErr.wait, c.MaxDelay)
+			}
+			log.Printf("Retrying %s in %v (attempt %d/%d): %v", url, delay, attempts+1, c.MaxRetries+1, lastErr)
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+		attempts++
+
+		body, ferr := c.do(ctx, url, query)
+		if ferr == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err() // Shutting down, not an upstream failure
+		}
+		lastErr = ferr
+		if !ferr.retryable {
+			break
+		}
+	}
+
+	if err := database.RecordFetchFailure(ctx, url, lastErr.status, attempts, lastErr.Error()); err != nil {
+		log.Printf("Error recording fetch failure for %s: %v", url, err)
+	}
+	return nil, fmt.Errorf("fetching %s failed after %d attempts: %w", url, attempts, lastErr)
+}
+
+// do performs a single request and validates the response
+func (c *FetchClient) do(ctx context.Context, url string, query map[string]string) ([]byte, *fetchError) {
+	resp, err := c.client.R().SetContext(ctx).SetQueryParams(query).Get(url)
+	if err != nil {
+		return nil, &fetchError{err: err, retryable: true}
+	}
+
+	status := resp.StatusCode()
+	switch {
+	case status == http.StatusTooManyRequests || status >= 500:
+		return nil, &fetchError{
+			status:    status,
+			err:       fmt.Errorf("%s", http.StatusText(status)),
+			retryable: true,
+			wait:      parseRetryAfter(resp.Header().Get("Retry-After")),
+		}
+	case status < 200 || status >= 300:
+		return nil, &fetchError{status: status, err: fmt.Errorf("%s", http.StatusText(status))}
+	}
+
+	// An HTML error page served with 200 would otherwise reach json.Unmarshal.
+	// Asking again won't turn it into JSON, so it is not retried. Without a
+	// Content-Type the body itself has to look like a JSON object or array.
+	contentType := resp.Header().Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "json") {
+		return nil, &fetchError{status: status, err: fmt.Errorf("unexpected content type %q", contentType)}
+	}
+	if contentType == "" && !looksLikeJSON(resp.Body()) {
+		return nil, &fetchError{status: status, err: fmt.Errorf("response without a content type is not JSON")}
+	}
+
+	return resp.Body(), nil
+}
+
+// looksLikeJSON reports whether body starts like a JSON object or array
+func looksLikeJSON(body []byte) bool {
+	body = bytes.TrimSpace(body)
+	return len(body) > 0 && (body[0] == '{' || body[0] == '[')
+}
+
+// backoff returns the exponential delay for a retry, with full jitter
+func (c *FetchClient) backoff(attempt int) time.Duration {
+	delay := c.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// sleepContext waits for d, returning early with ctx's error if it is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,168 @@
+package scripts
+
+import (
+	"context"
+	"flipAssistant/database"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient returns a fetch client whose retries wait milliseconds, not seconds
+func testClient() *FetchClient {
+	c := NewFetchClient()
+	c.MaxRetries = 2
+	c.BaseDelay = time.Millisecond
+	c.MaxDelay = 10 * time.Millisecond
+	return c
+}
+
+// serveSequence answers each request with the next response function, repeating
+// the last one once they run out, and counts the requests
+func serveSequence(t *testing.T, responses ...http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		responses[min(n, len(responses))-1](w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func status(code int, headers ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.WriteHeader(code)
+	}
+}
+
+func jsonBody(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}
+}
+
+// fetchFailure returns the status and attempts recorded for url, if any
+func fetchFailure(t *testing.T, url string) (status, attempts int, found bool) {
+	t.Helper()
+	err := database.DB.QueryRow(`SELECT status_code, attempts FROM fetch_failures WHERE url = ?`, url).Scan(&status, &attempts)
+	if err != nil {
+		return 0, 0, false
+	}
+	return status, attempts, true
+}
+
+func TestFetchClientRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []http.HandlerFunc
+		wantCalls int32
+		wantErr   bool
+	}{
+		{"ok", []http.HandlerFunc{jsonBody(`{"data":{}}`)}, 1, false},
+		{"429 then ok", []http.HandlerFunc{status(http.StatusTooManyRequests), jsonBody(`{"data":{}}`)}, 2, false},
+		{"5xx then ok", []http.HandlerFunc{status(http.StatusBadGateway), status(http.StatusServiceUnavailable), jsonBody(`{"data":{}}`)}, 3, false},
+		{"gives up after MaxRetries", []http.HandlerFunc{status(http.StatusServiceUnavailable)}, 3, true},
+		{"4xx is not retried", []http.HandlerFunc{status(http.StatusNotFound)}, 1, true},
+		{"html is not retried", []http.HandlerFunc{status(http.StatusOK, "Content-Type", "text/html")}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := serveSequence(t, tt.responses...)
+
+			body, err := testClient().Get(context.Background(), srv.URL+"/latest", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(body) != `{"data":{}}` {
+				t.Errorf("body %q", body)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestFetchClientRetryAfter(t *testing.T) {
+	// The server asks for an hour; the client waits at most MaxDelay
+	srv, calls := serveSequence(t, status(http.StatusTooManyRequests, "Retry-After", "3600"), jsonBody(`{}`))
+
+	start := time.Now()
+	if _, err := testClient().Get(context.Background(), srv.URL+"/latest", nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, want Retry-After capped at MaxDelay", elapsed)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("%d requests, want 2", calls.Load())
+	}
+
+	if got := parseRetryAfter("120"); got != 2*time.Minute {
+		t.Errorf("parseRetryAfter(120) = %v", got)
+	}
+	if got := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(date in an hour) = %v", got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("parseRetryAfter(soon) = %v, want 0", got)
+	}
+}
+
+func TestFetchClientWithoutContentType(t *testing.T) {
+	untyped := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header()["Content-Type"] = nil // Stop net/http sniffing one
+			fmt.Fprint(w, body)
+		}
+	}
+
+	srv, _ := serveSequence(t, untyped(` {"data":{}}`))
+	if _, err := testClient().Get(context.Background(), srv.URL+"/latest", nil); err != nil {
+		t.Errorf("untyped JSON: %v", err)
+	}
+
+	srv, calls := serveSequence(t, untyped("<html>Maintenance</html>"))
+	_, err := testClient().Get(context.Background(), srv.URL+"/latest", nil)
+	if err == nil || !strings.Contains(err.Error(), "not JSON") {
+		t.Errorf("untyped HTML: error %v, want not JSON", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("untyped HTML: %d requests, want 1", calls.Load())
+	}
+}
+
+func TestFetchClientRecordsFailures(t *testing.T) {
+	srv, _ := serveSequence(t, status(http.StatusServiceUnavailable))
+	url := srv.URL + "/dmm/latest"
+
+	if _, err := testClient().Get(context.Background(), url, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	code, attempts, found := fetchFailure(t, url)
+	if !found || code != http.StatusServiceUnavailable || attempts != 3 {
+		t.Errorf("recorded failure %v: status %d after %d attempts, want 503 after 3", found, code, attempts)
+	}
+
+	// A request abandoned at shutdown is not an upstream failure
+	srv, _ = serveSequence(t, status(http.StatusServiceUnavailable, "Retry-After", "1"))
+	url = srv.URL + "/osrs/latest"
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := testClient().Get(ctx, url, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, _, found := fetchFailure(t, url); found {
+		t.Error("cancelled request was recorded as a fetch failure")
+	}
+}
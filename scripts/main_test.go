@@ -0,0 +1,27 @@
+package scripts
+
+import (
+	"flipAssistant/database"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMain runs the package's tests against a fresh SQLite database
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "flipassistant-scripts")
+	if err != nil {
+		panic(err)
+	}
+	database.InitDB(database.Options{
+		DSN:         filepath.Join(dir, "test.db"),
+		BusyTimeout: 5 * time.Second,
+		WAL:         true,
+		MaxReaders:  4,
+	})
+	code := m.Run()
+	database.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
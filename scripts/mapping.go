@@ -0,0 +1,101 @@
+package scripts
+
+import (
+	"context"
+	"encoding/json"
+	"flipAssistant/database"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// RefreshItemCatalog downloads the /mapping endpoint of the wiki API at baseURL
+// (names, buy limits, alch and store values for every GE-tradeable item) into
+// item_catalog. Items are the same in every game mode, so the main game's
+// mapping is used.
+func RefreshItemCatalog(ctx context.Context, baseURL string) error {
+	body, err := NewFetchClient().Get(ctx, wikiURL(baseURL, database.DefaultGameMode, "mapping"), nil)
+	if err != nil {
+		return err
+	}
+
+	items, err := parseMapping(body)
+	if err != nil {
+		return fmt.Errorf("error parsing mapping response: %v", err)
+	}
+
+	if err := database.StoreItemCatalog(ctx, items); err != nil {
+		return err
+	}
+
+	log.Printf("Item catalog refreshed: %d items", len(items))
+	return nil
+}
+
+// ImportItemsFile stores a local copy of the /mapping response, such as one
+// saved with curl, into item_catalog and reloads the catalog from it
+func ImportItemsFile(ctx context.Context, path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	items, err := parseMapping(body)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	if err := database.StoreItemCatalog(ctx, items); err != nil {
+		return err
+	}
+
+	log.Printf("Item catalog loaded from %s: %d items", path, len(items))
+	return nil
+}
+
+// WatchItemsFile imports the items file again whenever its modification time
+// or size changes, checking every interval until ctx is cancelled. A file that
+// is missing or fails to import is logged and retried on its next change.
+func WatchItemsFile(ctx context.Context, path string, interval time.Duration) {
+	var lastMod time.Time
+	var lastSize int64
+	if info, err := os.Stat(path); err == nil {
+		lastMod, lastSize = info.ModTime(), info.Size()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = info.ModTime(), info.Size()
+
+		if err := ImportItemsFile(ctx, path); err != nil && ctx.Err() == nil {
+			log.Printf("Error reloading item catalog from %s: %v", path, err)
+		}
+	}
+}
+
+// parseMapping decodes a /mapping response
+func parseMapping(body []byte) ([]database.Item, error) {
+	var items []database.Item
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no items")
+	}
+	return items, nil
+}
@@ -0,0 +1,76 @@
+package scripts
+
+import (
+	"context"
+	"flipAssistant/database"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForCatalog polls until the catalog names itemID name, or fails the test
+// after a second
+func waitForCatalog(t *testing.T, itemID int, name string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for database.Catalog.Name(itemID) != name {
+		if time.Now().After(deadline) {
+			t.Fatalf("catalog names item %d %q, want %q", itemID, database.Catalog.Name(itemID), name)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatchItemsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.json")
+	write := func(body string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`[{"id": 2, "name": "Cannonball", "limit": 11000}]`)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := ImportItemsFile(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		WatchItemsFile(ctx, path, 5*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-watched
+	}()
+	// Let the watcher note the file as imported before changing it
+	time.Sleep(20 * time.Millisecond)
+
+	// A size change is picked up
+	write(`[{"id": 2, "name": "Cannonballs", "limit": 11000}]`)
+	waitForCatalog(t, 2, "Cannonballs")
+
+	// So is a same-size rewrite with a new modification time
+	write(`[{"id": 2, "name": "Cannonbally", "limit": 11000}]`)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	waitForCatalog(t, 2, "Cannonbally")
+
+	// A broken file is skipped and the catalog stays as it was
+	write(`[{"id": 2, "name": `)
+	time.Sleep(50 * time.Millisecond)
+	if name := database.Catalog.Name(2); name != "Cannonbally" {
+		t.Errorf("after a broken file the catalog names item 2 %q", name)
+	}
+
+	// Fixing the file imports it again, dropping items it no longer lists
+	write(`[{"id": 560, "name": "Death rune", "limit": 25000}]`)
+	waitForCatalog(t, 560, "Death rune")
+	if _, ok := database.Catalog.Item(2); ok {
+		t.Error("item 2 is still in the catalog after the file dropped it")
+	}
+}
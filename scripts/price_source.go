@@ -0,0 +1,184 @@
+package scripts
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wikiURL builds the URL of a prices API endpoint for one game mode. Each game
+// mode's market is served under its own prefix, e.g. /osrs/latest or /dmm/latest.
+func wikiURL(baseURL, mode, endpoint string) string {
+	return baseURL + "/" + mode + "/" + endpoint
+}
+
+// PriceSource supplies snapshots of the latest quote for every item, keyed by
+// item ID as in the wiki's /latest response
+type PriceSource interface {
+	// Name identifies the source in logs
+	Name() string
+	// FetchLatest returns the next snapshot of quotes
+	FetchLatest(ctx context.Context) (map[string]OSRSItem, error)
+}
+
+// latestResponse mirrors the body of the /latest endpoint
+type latestResponse struct {
+	Data map[string]OSRSItem `json:"data"`
+}
+
+// parseLatest decodes a /latest payload
+func parseLatest(body []byte) (map[string]OSRSItem, error) {
+	var response latestResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error parsing latest prices: %v", err)
+	}
+	return response.Data, nil
+}
+
+// LiveSource fetches one game mode's quotes from the OSRS Wiki API
+type LiveSource struct {
+	BaseURL string
+	Mode    string
+	client  *FetchClient
+}
+
+// NewLiveSource creates a source for one game mode of the wiki API at baseURL
+func NewLiveSource(baseURL, mode string) *LiveSource {
+	return &LiveSource{BaseURL: baseURL, Mode: mode, client: NewFetchClient()}
+}
+
+func (s *LiveSource) Name() string { return "live" }
+
+func (s *LiveSource) FetchLatest(ctx context.Context) (map[string]OSRSItem, error) {
+	body, err := s.client.Get(ctx, wikiURL(s.BaseURL, s.Mode, "latest"), nil)
+	if err != nil {
+		return nil, err
+	}
+	archiveSnapshot(ctx, s.Mode, "latest", body)
+	return parseLatest(body)
+}
+
+// FixtureSource serves a /latest payload from a JSON file on disk. The file is
+// re-read on every fetch, so it can be edited while the server runs.
+type FixtureSource struct {
+	Path string
+}
+
+// NewFixtureSource creates a source backed by the JSON file at path
+func NewFixtureSource(path string) *FixtureSource {
+	return &FixtureSource{Path: path}
+}
+
+func (s *FixtureSource) Name() string { return "fixture" }
+
+func (s *FixtureSource) FetchLatest(ctx context.Context) (map[string]OSRSItem, error) {
+	body, err := readSnapshotFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return parseLatest(body)
+}
+
+// ReplaySource steps through a directory of archived /latest snapshots, one
+// per fetch. Files are named by their unix fetch time (e.g. 1700000000.json or
+// 1700000000.json.gz) and are replayed in that order; Speed compresses the gaps
+// between them, so 60 replays an hour of snapshots per minute and 0 replays
+// them as fast as they are requested. After the last snapshot FetchLatest
+// returns io.EOF.
+type ReplaySource struct {
+	Dir   string
+	Speed float64
+
+	files   []replayFile
+	next    int
+	started time.Time
+}
+
+type replayFile struct {
+	path    string
+	fetched time.Time
+}
+
+// NewReplaySource indexes the snapshots in dir for replay at the given speed
+func NewReplaySource(dir string, speed float64) (*ReplaySource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading replay directory: %v", err)
+	}
+
+	var files []replayFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.gz")) {
+			continue
+		}
+		unix, err := strconv.ParseInt(strings.SplitN(name, ".", 2)[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("replay file %s is not named by unix timestamp", name)
+		}
+		files = append(files, replayFile{path: filepath.Join(dir, name), fetched: time.Unix(unix, 0)})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no snapshots found in %s", dir)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].fetched.Before(files[j].fetched)
+	})
+
+	return &ReplaySource{Dir: dir, Speed: speed, files: files}, nil
+}
+
+func (s *ReplaySource) Name() string { return "replay" }
+
+func (s *ReplaySource) FetchLatest(ctx context.Context) (map[string]OSRSItem, error) {
+	if s.next >= len(s.files) {
+		return nil, io.EOF
+	}
+
+	file := s.files[s.next]
+	if s.next == 0 {
+		s.started = time.Now()
+	} else if s.Speed > 0 {
+		// Wait until the scaled gap since the first snapshot has elapsed
+		offset := time.Duration(float64(file.fetched.Sub(s.files[0].fetched)) / s.Speed)
+		if err := sleepContext(ctx, time.Until(s.started.Add(offset))); err != nil {
+			return nil, err
+		}
+	}
+	s.next++
+
+	body, err := readSnapshotFile(file.path)
+	if err != nil {
+		return nil, err
+	}
+	return parseLatest(body)
+}
+
+// readSnapshotFile reads a JSON payload, transparently decompressing .gz files
+func readSnapshotFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,195 @@
+package scripts
+
+import (
+	"context"
+	"database/sql"
+	"flipAssistant/database"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Quality flags stored with each item_prices row
+const (
+	QualityOK         = "ok"          // Passed every enabled rule
+	QualityNoBaseline = "no_baseline" // Passed, but too little history to check for spikes
+	QualityAveraged   = "averaged"    // Bucket average from /timeseries, not a live quote
+	QualityUnchecked  = "unchecked"   // Stored before the quality gate existed
+)
+
+// QualityRules configures the data-quality gate applied to incoming quotes.
+// Quotes failing a rule are written to price_quarantine instead of item_prices.
+type QualityRules struct {
+	// RejectMissingSide rejects quotes where the buy or sell side has never traded
+	RejectMissingSide bool
+	// MaxJump rejects a side priced more than MaxJump times above or below the
+	// median of the item's recent prices (0 disables the rule)
+	MaxJump float64
+	// MedianWindow is how many recent quotes the median is taken over. Quotes
+	// quarantined as spikes count too, so a move that lasts for more than half
+	// the window becomes the new baseline instead of being rejected forever.
+	MedianWindow int
+	// MaxQuoteAge rejects a quote whose older side traded more than this long
+	// before the newest trade in the snapshot (0 disables the rule)
+	MaxQuoteAge time.Duration
+}
+
+// Quality holds the rules used by the ingestion path
+var Quality = QualityRules{
+	RejectMissingSide: true,
+	MaxJump:           3,
+	MedianWindow:      12,
+	MaxQuoteAge:       24 * time.Hour,
+}
+
+// Check validates a quote against the rules. reference is the time the
+// snapshot was current, so archived snapshots are judged by the same clock as
+// when they were fetched. It returns the quality flag to store the quote with,
+// or a non-empty reason if the quote must be quarantined.
+func (r QualityRules) Check(ctx context.Context, q database.Querier, mode string, itemID int, quote OSRSItem, reference time.Time) (flag, reason string, err error) {
+	if quote.Low == 0 || quote.High == 0 || quote.LowTime == 0 || quote.HighTime == 0 {
+		if r.RejectMissingSide {
+			return "", "missing_side", nil
+		}
+	}
+
+	if r.MaxQuoteAge > 0 {
+		oldest := quote.HighTime
+		if quote.LowTime != 0 && (oldest == 0 || quote.LowTime < oldest) {
+			oldest = quote.LowTime
+		}
+		if oldest != 0 && reference.Sub(time.Unix(int64(oldest), 0)) > r.MaxQuoteAge {
+			return "", "stale", nil
+		}
+	}
+
+	if r.MaxJump <= 0 {
+		return QualityOK, "", nil
+	}
+
+	medianBuy, medianSell, ok, err := recentMedians(ctx, q, mode, itemID, r.MedianWindow)
+	if err != nil {
+		return "", "", err
+	}
+	if !ok {
+		return QualityNoBaseline, "", nil
+	}
+	if isJump(float64(quote.Low), medianBuy, r.MaxJump) {
+		return "", fmt.Sprintf("spike: buy %d vs median %.0f", quote.Low, medianBuy), nil
+	}
+	if isJump(float64(quote.High), medianSell, r.MaxJump) {
+		return "", fmt.Sprintf("spike: sell %d vs median %.0f", quote.High, medianSell), nil
+	}
+	return QualityOK, "", nil
+}
+
+// isJump reports whether price is more than maxJump times above or below median
+func isJump(price, median, maxJump float64) bool {
+	if price <= 0 || median <= 0 {
+		return false
+	}
+	return price > median*maxJump || price < median/maxJump
+}
+
+// recentMedians returns the median buy and sell price over an item's most
+// recent quotes, stored or quarantined as spikes; ok is false when there are
+// fewer than half a window. A quarantined quote is ordered by its latest trade,
+// the same time a stored one is timestamped with.
+func recentMedians(ctx context.Context, q database.Querier, mode string, itemID, window int) (buy, sell float64, ok bool, err error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT observed, buy_price, sell_price FROM (
+			SELECT timestamp AS observed, buy_price, sell_price FROM item_prices
+			WHERE item_id = ? AND game_mode = ?
+			ORDER BY timestamp DESC
+			LIMIT ?
+		) AS stored
+		UNION ALL
+		SELECT observed, buy_price, sell_price FROM (
+			SELECT CASE WHEN sell_time IS NULL OR buy_time > sell_time THEN buy_time ELSE sell_time END AS observed,
+				buy_price, sell_price
+			FROM price_quarantine
+			WHERE item_id = ? AND game_mode = ? AND reason LIKE 'spike%'
+			ORDER BY observed DESC
+			LIMIT ?
+		) AS spikes
+		ORDER BY observed DESC
+		LIMIT ?
+	`, itemID, mode, window, itemID, mode, window, window)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer rows.Close()
+
+	var buys, sells []float64
+	for rows.Next() {
+		var observed interface{}
+		var b, s sql.NullInt64
+		if err := rows.Scan(&observed, &b, &s); err != nil {
+			return 0, 0, false, err
+		}
+		if b.Int64 > 0 {
+			buys = append(buys, float64(b.Int64))
+		}
+		if s.Int64 > 0 {
+			sells = append(sells, float64(s.Int64))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, false, err
+	}
+
+	minRows := (window + 1) / 2
+	if len(buys) < minRows || len(sells) < minRows {
+		return 0, 0, false, nil
+	}
+	return median(buys), median(sells), true, nil
+}
+
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// quarantineQuote records a rejected quote with the reason it failed. The
+// same quote repeated in later snapshots is only recorded once.
+func quarantineQuote(ctx context.Context, tx *sql.Tx, mode string, itemID int, quote OSRSItem, reason string) error {
+	buyTime := nullableTimestamp(quote.LowTime)
+	sellTime := nullableTimestamp(quote.HighTime)
+
+	var exists int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM price_quarantine
+		WHERE item_id = ? AND game_mode = ? AND buy_time IS NOT DISTINCT FROM ? AND sell_time IS NOT DISTINCT FROM ?
+	`, itemID, mode, buyTime, sellTime).Scan(&exists)
+	if err != nil || exists > 0 {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO price_quarantine (item_id, game_mode, buy_price, sell_price, buy_time, sell_time, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, itemID, mode, quote.Low, quote.High, buyTime, sellTime, reason)
+	return err
+}
+
+// snapshotReference returns the newest trade time in a snapshot, used as
+// "now" when judging quote age
+func snapshotReference(prices map[string]OSRSItem) time.Time {
+	newest := 0
+	for _, quote := range prices {
+		if quote.HighTime > newest {
+			newest = quote.HighTime
+		}
+		if quote.LowTime > newest {
+			newest = quote.LowTime
+		}
+	}
+	if newest == 0 {
+		return time.Now()
+	}
+	return time.Unix(int64(newest), 0)
+}
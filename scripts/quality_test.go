@@ -0,0 +1,166 @@
+package scripts
+
+import (
+	"context"
+	"flipAssistant/database"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQualityCheck(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	fresh := int(now.Unix())
+	old := int(now.Add(-48 * time.Hour).Unix())
+
+	tests := []struct {
+		name       string
+		rules      QualityRules
+		quote      OSRSItem
+		wantFlag   string
+		wantReason string
+	}{
+		{"no history", Quality, OSRSItem{High: 110, HighTime: fresh, Low: 100, LowTime: fresh}, QualityNoBaseline, ""},
+		{"never sold", Quality, OSRSItem{Low: 100, LowTime: fresh}, "", "missing_side"},
+		{"never bought", Quality, OSRSItem{High: 110, HighTime: fresh}, "", "missing_side"},
+		{"missing side allowed", QualityRules{MaxJump: 3, MedianWindow: 12}, OSRSItem{High: 110, HighTime: fresh}, QualityNoBaseline, ""},
+		{"stale side", Quality, OSRSItem{High: 110, HighTime: fresh, Low: 100, LowTime: old}, "", "stale"},
+		{"age check off", QualityRules{RejectMissingSide: true, MedianWindow: 12}, OSRSItem{High: 110, HighTime: fresh, Low: 100, LowTime: old}, QualityOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag, reason, err := tt.rules.Check(context.Background(), database.DB, "osrs", 920001, tt.quote, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flag != tt.wantFlag || reason != tt.wantReason {
+				t.Errorf("got flag %q reason %q, want %q and %q", flag, reason, tt.wantFlag, tt.wantReason)
+			}
+		})
+	}
+}
+
+// quoteFeed stores one snapshot per quote for an item, each traded ten
+// minutes after the last
+type quoteFeed struct {
+	t      *testing.T
+	itemID int
+	traded time.Time
+}
+
+// next stores a quote at buy and sell and reports whether it was stored
+func (f *quoteFeed) next(buy, sell int) bool {
+	f.t.Helper()
+	f.traded = f.traded.Add(10 * time.Minute)
+	at := int(f.traded.Unix())
+	return f.repeat(OSRSItem{High: sell, HighTime: at, Low: buy, LowTime: at})
+}
+
+// repeat stores a snapshot holding quote and reports whether it was stored
+func (f *quoteFeed) repeat(quote OSRSItem) bool {
+	f.t.Helper()
+	stats, err := storeSnapshot(context.Background(), "osrs", []int{f.itemID}, map[string]OSRSItem{fmt.Sprint(f.itemID): quote})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return len(stats.Inserted) == 1
+}
+
+// qualityFlags returns the quality flag of each stored row of an item, oldest first
+func qualityFlags(t *testing.T, itemID int) []string {
+	t.Helper()
+	rows, err := database.DB.Query(`SELECT quality FROM item_prices WHERE item_id = ? ORDER BY timestamp`, itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var flags []string
+	for rows.Next() {
+		var flag string
+		if err := rows.Scan(&flag); err != nil {
+			t.Fatal(err)
+		}
+		flags = append(flags, flag)
+	}
+	return flags
+}
+
+// quarantined returns the reasons recorded for an item's quarantined quotes
+func quarantined(t *testing.T, itemID int) []string {
+	t.Helper()
+	rows, err := database.DB.Query(`SELECT reason FROM price_quarantine WHERE item_id = ? ORDER BY id`, itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var reasons []string
+	for rows.Next() {
+		var reason string
+		if err := rows.Scan(&reason); err != nil {
+			t.Fatal(err)
+		}
+		reasons = append(reasons, reason)
+	}
+	return reasons
+}
+
+func TestQualitySpikes(t *testing.T) {
+	const itemID = 920002
+	feed := &quoteFeed{t: t, itemID: itemID, traded: time.Now().Add(-24 * time.Hour)}
+
+	// Until half a window is stored there is nothing to compare against
+	for i := 0; i < Quality.MedianWindow; i++ {
+		if !feed.next(100+i%3, 110+i%3) {
+			t.Fatalf("baseline quote %d was not stored", i)
+		}
+	}
+	flags := qualityFlags(t, itemID)
+	half := (Quality.MedianWindow + 1) / 2
+	for i, flag := range flags {
+		want := QualityOK
+		if i < half {
+			want = QualityNoBaseline
+		}
+		if flag != want {
+			t.Errorf("baseline quote %d flagged %q, want %q", i, flag, want)
+		}
+	}
+
+	// A one-off spike is quarantined once, however many snapshots list it
+	if feed.next(1000, 1100) {
+		t.Fatal("spike was stored")
+	}
+	spike := OSRSItem{High: 1100, HighTime: int(feed.traded.Unix()), Low: 1000, LowTime: int(feed.traded.Unix())}
+	if feed.repeat(spike) {
+		t.Fatal("repeated spike was stored")
+	}
+	reasons := quarantined(t, itemID)
+	if len(reasons) != 1 || !strings.HasPrefix(reasons[0], "spike: buy 1000") {
+		t.Fatalf("quarantined %q, want one buy spike", reasons)
+	}
+	if !feed.next(101, 111) {
+		t.Fatal("quote back at the old level was not stored")
+	}
+
+	// A price that stays at its new level becomes the baseline
+	rejected := 0
+	for !feed.next(1000, 1100) {
+		rejected++
+		if rejected > Quality.MedianWindow {
+			t.Fatalf("still quarantining after %d quotes at the new level", rejected)
+		}
+	}
+	if rejected < 2 || rejected > half {
+		t.Errorf("new level accepted after %d quarantined quotes, want between 2 and %d", rejected, half)
+	}
+	for i := 0; i < 3; i++ {
+		if !feed.next(1000+i, 1100+i) {
+			t.Errorf("quote %d at the new level was quarantined", i)
+		}
+	}
+	if n := len(quarantined(t, itemID)); n != rejected+1 {
+		t.Errorf("%d quotes quarantined, want %d", n, rejected+1)
+	}
+}
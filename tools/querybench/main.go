@@ -0,0 +1,280 @@
+// Command querybench measures the item_prices queries behind analytics, item
+// history and ingestion on a synthetic database, with and without the indexes
+// added by migration 2. For example:
+//
+//	go run ./tools/querybench -items 3000 -rows 1000 -queries 20
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"flipAssistant/database"
+	"flipAssistant/routes"
+	"flipAssistant/scripts"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// indexMigration is the migration whose indexes are being measured
+const indexMigration = 2
+
+// rowInterval spaces the synthetic quotes like the 5-minute /latest cadence
+const rowInterval = 5 * time.Minute
+
+func main() {
+	dbPath := flag.String("db", "", "database file to build (default: a temporary file, removed afterwards)")
+	items := flag.Int("items", 3000, "number of synthetic items")
+	rows := flag.Int("rows", 1000, "price rows per item")
+	queries := flag.Int("queries", 20, "random items measured per operation")
+	flag.Parse()
+
+	path := *dbPath
+	if path == "" {
+		dir, err := os.MkdirTemp("", "querybench")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, "bench.db")
+	}
+
+	opts := database.DefaultOptions
+	opts.DSN = path
+	database.InitDB(opts)
+	defer database.Close()
+
+	ctx := context.Background()
+	indexes, create := migrationIndexes()
+
+	// Load without the indexes, which is also faster, then measure both ways
+	for _, name := range indexes {
+		if _, err := database.DB.ExecContext(ctx, "DROP INDEX IF EXISTS "+name); err != nil {
+			log.Fatal(err)
+		}
+	}
+	start := time.Now()
+	last, err := generate(ctx, *items, *rows)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Generated %d rows for %d items in %v", *items**rows, *items, time.Since(start).Round(time.Millisecond))
+
+	sample := make([]int, *queries)
+	rng := rand.New(rand.NewSource(1))
+	for i := range sample {
+		sample[i] = rng.Intn(*items) + 1
+	}
+
+	without := runSuite(ctx, sample, last.Add(rowInterval))
+
+	start = time.Now()
+	if _, err := database.DB.ExecContext(ctx, create); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Created indexes %s in %v", strings.Join(indexes, ", "), time.Since(start).Round(time.Millisecond))
+	with := make(map[string]time.Duration)
+	for _, r := range runSuite(ctx, sample, last.Add(2*rowInterval)) {
+		with[r.name] = r.mean
+	}
+
+	fmt.Printf("\n%d rows, %d items, %d queries per operation (mean per call)\n\n", *items**rows, *items, *queries)
+	fmt.Printf("%-28s %14s %14s %10s\n", "operation", "no indexes", "indexes", "speedup")
+	for _, r := range without {
+		w := with[r.name]
+		fmt.Printf("%-28s %14v %14v %9.1fx\n", r.name, r.mean.Round(time.Microsecond), w.Round(time.Microsecond),
+			float64(r.mean)/float64(w))
+	}
+}
+
+// migrationIndexes returns the names of the indexes created by the index
+// migration and its SQL
+func migrationIndexes() ([]string, string) {
+	migrations, err := database.LoadMigrations()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(migrations) < indexMigration {
+		log.Fatalf("migration %d not found", indexMigration)
+	}
+	m := migrations[indexMigration-1]
+
+	var names []string
+	for _, match := range regexp.MustCompile(`CREATE INDEX IF NOT EXISTS (\w+)`).FindAllStringSubmatch(m.SQL, -1) {
+		names = append(names, match[1])
+	}
+	return names, m.SQL
+}
+
+// generate fills item_prices with a random walk per item and returns the time
+// of the newest row
+func generate(ctx context.Context, items, rows int) (time.Time, error) {
+	const batch = 100
+	first := time.Now().Add(-time.Duration(rows) * rowInterval).Truncate(time.Minute)
+	rng := rand.New(rand.NewSource(42))
+
+	placeholders := strings.TrimSuffix(strings.Repeat("(?, 'osrs', ?, ?, ?, ?, ?, 'ok'), ", batch), ", ")
+	query := `INSERT INTO item_prices (item_id, game_mode, timestamp, buy_price, sell_price, buy_time, sell_time, quality) VALUES ` + placeholders
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer stmt.Close()
+
+	// Rows are written in time order across all items, as ingestion would
+	args := make([]interface{}, 0, batch*6)
+	prices := make([]float64, items+1)
+	for id := 1; id <= items; id++ {
+		prices[id] = float64(100 + rng.Intn(1000000))
+	}
+	for r := 0; r < rows; r++ {
+		timestamp := first.Add(time.Duration(r) * rowInterval).UTC().Format(database.TimestampLayout)
+		for id := 1; id <= items; id++ {
+			prices[id] *= 1 + (rng.Float64()-0.5)*0.02
+			buy := int(prices[id])
+			args = append(args, id, timestamp, buy, buy+buy/50+1, timestamp, timestamp)
+			if len(args) == cap(args) {
+				if _, err := stmt.ExecContext(ctx, args...); err != nil {
+					return time.Time{}, err
+				}
+				args = args[:0]
+			}
+		}
+	}
+	for i := 0; i < len(args); i += 6 {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO item_prices (item_id, game_mode, timestamp, buy_price, sell_price, buy_time, sell_time, quality)
+			VALUES (?, 'osrs', ?, ?, ?, ?, ?, 'ok')
+		`, args[i:i+6]...); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return first.Add(time.Duration(rows-1) * rowInterval), tx.Commit()
+}
+
+type result struct {
+	name string
+	mean time.Duration
+}
+
+// runSuite times each operation over the sample items. Ingestion stores one
+// new quote per sampled item observed at quoteTime.
+func runSuite(ctx context.Context, sample []int, quoteTime time.Time) []result {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.GET("/item-history/:id", routes.GetItemHistory(database.NewSQLStore(database.ReadDB)))
+
+	// Stored indicator state, so ingestion and the incremental update only
+	// fold in new quotes
+	if _, err := scripts.RebuildAnalytics(ctx, database.DefaultGameMode, sample); err != nil {
+		log.Fatal(err)
+	}
+	states := make(map[int]database.IndicatorState, len(sample))
+	for _, itemID := range sample {
+		state, err := database.LoadIndicatorState(ctx, database.DefaultGameMode, itemID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		states[itemID] = state
+	}
+
+	ops := []struct {
+		name string
+		run  func(itemID int) error
+	}{
+		{"CalculateSMA5", func(itemID int) error {
+			_, _, err := database.CalculateSMA5(ctx, database.DefaultGameMode, itemID)
+			return err
+		}},
+		{"ComputeItemAnalytics (full)", func(itemID int) error {
+			_, _, err := database.ComputeItemAnalytics(ctx, database.DefaultGameMode,
+				database.IndicatorState{ItemID: itemID, GameMode: database.DefaultGameMode})
+			return err
+		}},
+		{"ComputeItemAnalytics (state)", func(itemID int) error {
+			_, _, err := database.ComputeItemAnalytics(ctx, database.DefaultGameMode, states[itemID])
+			return err
+		}},
+		{"GET /item-history/:id", func(itemID int) error {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/item-history/"+strconv.Itoa(itemID), nil))
+			if w.Code != http.StatusOK {
+				return fmt.Errorf("item history returned %d", w.Code)
+			}
+			return nil
+		}},
+	}
+
+	var results []result
+	for _, op := range ops {
+		start := time.Now()
+		for _, itemID := range sample {
+			if err := op.run(itemID); err != nil {
+				log.Fatalf("%s: %v", op.name, err)
+			}
+		}
+		results = append(results, result{op.name, time.Since(start) / time.Duration(len(sample))})
+	}
+
+	// A fetch cycle for the sampled items: duplicate check, quality gate,
+	// insert and analytics
+	fixture, err := writeFixture(ctx, sample, quoteTime)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(fixture)
+	start := time.Now()
+	if err := scripts.FetchAndStorePricesForAllItems(ctx, scripts.NewFixtureSource(fixture), database.DefaultGameMode, sample); err != nil {
+		log.Fatalf("ingestion: %v", err)
+	}
+	results = append(results, result{"ingest (per item)", time.Since(start) / time.Duration(len(sample))})
+	return results
+}
+
+// writeFixture writes a /latest payload with a fresh quote for each item, a
+// little above its last stored prices so the quality gate accepts it
+func writeFixture(ctx context.Context, itemIDs []int, quoteTime time.Time) (string, error) {
+	data := make(map[string]scripts.OSRSItem, len(itemIDs))
+	for _, id := range itemIDs {
+		var buy, sell int
+		if err := database.ReadDB.QueryRowContext(ctx, `
+			SELECT buy_price, sell_price FROM item_prices
+			WHERE item_id = ? AND game_mode = ?
+			ORDER BY timestamp DESC LIMIT 1
+		`, id, database.DefaultGameMode).Scan(&buy, &sell); err != nil {
+			return "", err
+		}
+		data[strconv.Itoa(id)] = scripts.OSRSItem{
+			High: sell + 1, HighTime: int(quoteTime.Unix()),
+			Low: buy + 1, LowTime: int(quoteTime.Unix()),
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "querybench-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = f.Write(body)
+	return f.Name(), err
+}